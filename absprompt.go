@@ -23,22 +23,173 @@ type AbsPrompt struct {
 	line        string
 	pos         int
 	prompted    bool
+
+	// Highlighter, if non-nil, is used to style the input line as it's drawn
+	Highlighter Highlighter
+
+	// Menu lays out tab-completion candidates, which are drawn in the area set
+	// up by SetMenuArea.  It's nil (no menu is drawn) until SetMenuArea is
+	// called.
+	Menu *CompletionMenu
+
+	// menuX and menuY are the screen location of the menu's top-left corner,
+	// and menuRows holds whatever rows were last drawn there
+	menuX, menuY int
+	menuRows     []string
+
+	// ContinuationPrompt is printed at the start of each row after the first
+	// when the input has multiple lines.  Rows are drawn directly below the
+	// prompt's location.  It defaults to "... ".
+	ContinuationPrompt string
+
+	// Mask, if non-nil, hides the input as it's drawn
+	Mask *Mask
+
+	// shown is the text last drawn, which differs from line when masking, and
+	// revealed is whether the Reader's input was revealed at the time
+	shown    string
+	revealed bool
+
+	// redrawPending is set (with the Reader's lock held) when everything has
+	// to be drawn again on the next write
+	redrawPending bool
+
+	// ErrorStyle holds the SGR parameters used to draw validation errors.  It
+	// defaults to "31" (red).
+	ErrorStyle string
+
+	// errorX and errorY are where validation errors are drawn, with zero
+	// meaning directly below the input.  errorDrawn is the message currently
+	// on screen, drawn at errorDrawnX and errorDrawnY.
+	errorX, errorY           int
+	errorDrawn               string
+	errorDrawnX, errorDrawnY int
+
+	// Modes, if non-nil, are turned on while a line is being read, and turned
+	// off when ReadLine returns or Close is called
+	Modes *TerminalModes
+
+	// Cursor, if non-nil, changes the cursor's shape while a line is being
+	// read to show whether the Reader is in overwrite mode
+	Cursor *CursorStyle
+
+	// reading is true (with the Reader's lock held) while ReadLine is running,
+	// and cursorShape is the shape the cursor was last given
+	reading     bool
+	cursorShape CursorShape
 }
 
 // NewAbsPrompt returns an AbsPrompt which will read lines from r, write its
 // prompt and current line to w, and use p as the prompt string.
 func NewAbsPrompt(r io.Reader, w io.Writer, p string) *AbsPrompt {
-	var prompt = &AbsPrompt{Reader: NewReader(r), Out: w, buf: bytes.Buffer{}, x: 1, y: 1}
+	var prompt = &AbsPrompt{Reader: NewReader(r), Out: w, buf: bytes.Buffer{}, x: 1, y: 1, ContinuationPrompt: "... ", ErrorStyle: "31", cursorShape: cursorUnknown}
 	prompt.SetPrompt(p)
+	prompt.Reader.redraw = prompt.redraw
+	prompt.Reader.restoreCursor = prompt.restoreCursor
 	return prompt
 }
 
 // ReadLine delegates to the reader's ReadLine function
 func (p *AbsPrompt) ReadLine() (string, error) {
-	line, err := p.Reader.ReadLine()
+	return p.readLine(p.Reader.ReadLine)
+}
+
+// ReadLineWithDefault is ReadLine, but with text already filled in for the
+// user to edit, and the cursor at pos
+func (p *AbsPrompt) ReadLineWithDefault(text string, pos int) (string, error) {
+	return p.readLine(func() (string, error) {
+		return p.Reader.ReadLineWithDefault(text, pos)
+	})
+}
+
+// ReadPassword reads a line without saving it to history, drawing it with the
+// AbsPrompt's Mask, or with asterisks if Mask is nil
+func (p *AbsPrompt) ReadPassword() (line string, err error) {
+	var oldMask = p.Mask
+	if p.Mask == nil {
+		p.Mask = NewMask('*')
+	}
+	line, err = p.readLine(p.Reader.ReadPassword)
+	p.Mask = oldMask
+	return
+}
+
+// readLine turns on the AbsPrompt's Modes and calls read to get the user's
+// input
+func (p *AbsPrompt) readLine(read func() (string, error)) (string, error) {
+	defer p.disableModes()
+	if err := p.enableModes(p.Modes, p.Out); err != nil {
+		return "", err
+	}
+
+	p.setReading(true)
+	line, err := read()
+	p.setReading(false)
 	return line, err
 }
 
+// Close turns off the AbsPrompt's Modes and restores the cursor's shape if a
+// line is being read.  It's meant for cleaning up the terminal when exiting
+// in the middle of a ReadLine, such as from a signal handler.
+func (p *AbsPrompt) Close() error {
+	p.m.Lock()
+	p.restoreCursor()
+	p.m.Unlock()
+	return p.disableModes()
+}
+
+// setReading sets whether ReadLine is running, restoring the cursor's shape
+// once it's done
+func (p *AbsPrompt) setReading(reading bool) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	p.reading = reading
+	if !reading {
+		p.restoreCursor()
+	}
+}
+
+// writeCursor sets the cursor's shape for the Reader's mode if a line is
+// being read and the shape has changed
+func (p *AbsPrompt) writeCursor() {
+	if p.Cursor == nil {
+		return
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+	if !p.reading {
+		return
+	}
+	var shape = p.Cursor.shape(p.Reader.line.Overwrite)
+	if shape != p.cursorShape {
+		writeShape(p.Out, shape)
+		p.cursorShape = shape
+	}
+}
+
+// cursorChanged returns true if writeCursor has a new shape to set
+func (p *AbsPrompt) cursorChanged() bool {
+	if p.Cursor == nil {
+		return false
+	}
+
+	p.m.RLock()
+	defer p.m.RUnlock()
+	return p.reading && p.Cursor.shape(p.Reader.line.Overwrite) != p.cursorShape
+}
+
+// restoreCursor puts the cursor back to the Cursor's Restore shape if
+// writeCursor changed it
+func (p *AbsPrompt) restoreCursor() {
+	// lock has to be held here
+	if p.Cursor == nil || p.cursorShape == cursorUnknown {
+		return
+	}
+	writeShape(p.Out, p.Cursor.Restore)
+	p.cursorShape = cursorUnknown
+}
+
 // SetPrompt changes the current prompt.  This shouldn't be called while a
 // ReadLine is in progress.
 func (p *AbsPrompt) SetPrompt(s string) {
@@ -53,30 +204,89 @@ func (p *AbsPrompt) SetLocation(x, y int) {
 	p.y = y + 1
 }
 
+// SetMenuArea sets the rectangle in which tab-completion candidates will be
+// drawn, creating a default Menu if one isn't already set.  As with
+// SetLocation, x and y are zero-based.  Whatever is in this area when the menu
+// is drawn will be replaced by spaces once completion ends, so applications
+// which draw there should redraw that area as needed.
+func (p *AbsPrompt) SetMenuArea(x, y, width, height int) {
+	if p.Menu == nil {
+		p.Menu = NewCompletionMenu()
+	}
+	p.Menu.Width = width
+	p.Menu.MaxRows = height
+	p.menuX = x + 1
+	p.menuY = y + 1
+}
+
+// SetErrorLocation sets where validation errors are drawn, such as beside the
+// input rather than below it.  As with SetLocation, x and y are zero-based.
+func (p *AbsPrompt) SetErrorLocation(x, y int) {
+	p.errorX = x + 1
+	p.errorY = y + 1
+}
+
 // NeedWrite returns true if there are any pending changes to the line or
 // cursor position
 func (p *AbsPrompt) NeedWrite() bool {
 	line, pos := p.LinePos()
-	return line != p.line || pos != p.pos
+	return p.changed(line) || pos != p.pos || p.needsRedraw() || p.cursorChanged()
+}
+
+// redraw is called by the Reader, with its lock held, when the screen may
+// have been changed out from under us
+func (p *AbsPrompt) redraw() {
+	p.redrawPending = true
+}
+
+// needsRedraw returns true if redraw has been called since the last write
+func (p *AbsPrompt) needsRedraw() bool {
+	p.m.RLock()
+	defer p.m.RUnlock()
+	return p.redrawPending
+}
+
+// forgetDrawn makes the next write draw everything if redraw was called
+func (p *AbsPrompt) forgetDrawn() {
+	p.m.Lock()
+	var pending = p.redrawPending
+	p.redrawPending = false
+	if pending {
+		p.cursorShape = cursorUnknown
+	}
+	p.m.Unlock()
+
+	if pending {
+		p.prompted = false
+		p.line = ""
+		p.shown = ""
+		p.pos = -1
+		p.menuRows = nil
+		p.errorDrawn = ""
+	}
+}
+
+// changed returns true if line differs from the line last drawn, or the user
+// has since toggled whether masked input is revealed
+func (p *AbsPrompt) changed(line string) bool {
+	return line != p.line || (p.Mask != nil && p.Revealed() != p.revealed)
 }
 
 // WriteAll forces a write of the entire prompt
 func (p *AbsPrompt) WriteAll() {
-	line, pos := p.LinePos()
-
-	p.printAt(0, p.prompt+p.line)
-	p.pos = len(p.line)
+	p.forgetDrawn()
+	p.writeCursor()
+	_, pos := p.LinePos()
 
-	if p.line != line {
-		prevLine := p.line
+	p.PrintPrompt()
+	p.prompted = true
+	p.printLine()
 
-		lpl := len(prevLine)
-		ll := len(line)
-		bigger := lpl - ll
-		if bigger > 0 {
-			fmt.Fprintf(p.Out, strings.Repeat(" ", bigger))
-			p.pos += bigger
-		}
+	if p.writeMenu(true) {
+		p.pos = -1
+	}
+	if p.writeError(true) {
+		p.pos = -1
 	}
 
 	if p.pos != pos {
@@ -89,6 +299,8 @@ func (p *AbsPrompt) WriteAll() {
 // changed (line text or the cursor position).  It will also print the prompt
 // if that hasn't yet been printed.
 func (p *AbsPrompt) WriteChanges() {
+	p.forgetDrawn()
+	p.writeCursor()
 	line, pos := p.LinePos()
 
 	if !p.prompted {
@@ -96,18 +308,15 @@ func (p *AbsPrompt) WriteChanges() {
 		p.prompted = true
 	}
 
-	if p.line != line {
-		prevLine := p.line
-		p.line = line
-		p.PrintLine()
+	if p.changed(line) {
+		p.printLine()
+	}
 
-		lpl := len(prevLine)
-		ll := len(line)
-		bigger := lpl - ll
-		if bigger > 0 {
-			fmt.Fprintf(p.Out, strings.Repeat(" ", bigger))
-			p.pos += bigger
-		}
+	if p.WriteMenu() {
+		p.pos = -1
+	}
+	if p.writeError(false) {
+		p.pos = -1
 	}
 
 	if p.pos != pos {
@@ -121,6 +330,8 @@ func (p *AbsPrompt) WriteChanges() {
 // the cursor change where it makes sense, regardless of changes to the user's
 // input.
 func (p *AbsPrompt) WriteChangesNoCursor() {
+	p.forgetDrawn()
+	p.writeCursor()
 	line, pos := p.LinePos()
 	p.pos = pos
 
@@ -129,25 +340,81 @@ func (p *AbsPrompt) WriteChangesNoCursor() {
 		p.prompted = true
 	}
 
-	if p.line != line {
-		prevLine := p.line
-		p.line = line
-		p.PrintLine()
+	if p.changed(line) {
+		p.printLine()
+	}
+
+	p.WriteMenu()
+	p.writeError(false)
+}
 
-		lpl := len(prevLine)
-		ll := len(line)
-		bigger := lpl - ll
-		if bigger > 0 {
-			fmt.Fprintf(p.Out, strings.Repeat(" ", bigger))
-			p.pos += bigger
-		}
+// WriteMenu draws the tab-completion menu if it has changed since it was last
+// drawn, erasing any rows which are no longer needed, and returns true if
+// anything was written.  The cursor is left wherever the drawing ends.
+func (p *AbsPrompt) WriteMenu() bool {
+	return p.writeMenu(false)
+}
+
+// writeMenu implements WriteMenu, optionally drawing the menu even if it
+// hasn't changed
+func (p *AbsPrompt) writeMenu(force bool) bool {
+	if p.Menu == nil {
+		return false
+	}
+
+	p.m.Lock()
+	var rows = p.Reader.completionMenu(p.Menu)
+	p.m.Unlock()
+
+	if !force && strings.Join(rows, "\n") == strings.Join(p.menuRows, "\n") {
+		return false
+	}
+
+	for i, row := range rows {
+		fmt.Fprintf(p.Out, "\x1b[%d;%dH%s", p.menuY+i, p.menuX, row)
+	}
+	for i := len(rows); i < len(p.menuRows); i++ {
+		fmt.Fprintf(p.Out, "\x1b[%d;%dH%s", p.menuY+i, p.menuX, strings.Repeat(" ", p.Menu.Width))
+	}
+	p.menuRows = rows
+	return true
+}
+
+// writeError draws the Reader's validation error, or erases the one on screen
+// if it's gone, and returns true if anything was written.  Unless forced, the
+// error is only written if it has changed.
+func (p *AbsPrompt) writeError(force bool) bool {
+	var msg = p.ValidationMessage()
+	var x, y = p.errorX, p.errorY
+	if y == 0 {
+		x, y = p.x, p.y+strings.Count(p.shown, "\n")+1
+	}
+
+	if !force && msg == p.errorDrawn && x == p.errorDrawnX && y == p.errorDrawnY {
+		return false
 	}
+
+	var wrote = p.errorDrawn != "" || msg != ""
+	if p.errorDrawn != "" {
+		fmt.Fprintf(p.Out, "\x1b[%d;%dH%s", p.errorDrawnY, p.errorDrawnX, strings.Repeat(" ", VisualLength(p.errorDrawn)))
+	}
+	if msg != "" {
+		fmt.Fprintf(p.Out, "\x1b[%d;%dH%s%s%s", y, x, sgr(p.ErrorStyle), msg, sgrReset)
+	}
+	p.errorDrawn, p.errorDrawnX, p.errorDrawnY = msg, x, y
+	return wrote
 }
 
 // printAt moves to the position dx spaces from the start of the prompt's X
 // location and prints a string
 func (p *AbsPrompt) printAt(dx int, s string) {
-	fmt.Fprintf(p.Out, "\x1b[%d;%dH%s", p.y, p.x+dx, s)
+	p.printAtRow(0, dx, s)
+}
+
+// printAtRow is printAt for the given row of input below the prompt's Y
+// location
+func (p *AbsPrompt) printAtRow(row, dx int, s string) {
+	fmt.Fprintf(p.Out, "\x1b[%d;%dH%s", p.y+row, p.x+dx, s)
 }
 
 // PrintPrompt moves to the x/y coordinates of the prompt and prints the
@@ -160,13 +427,94 @@ func (p *AbsPrompt) PrintPrompt() {
 // PrintLine gets the current line and prints it to the screen just after the
 // prompt location
 func (p *AbsPrompt) PrintLine() {
-	p.line, _ = p.LinePos()
-	p.printAt(p.promptWidth, p.line)
-	p.pos = len(p.line)
+	p.printLine()
+}
+
+// printLine gets the current line and prints it, with each row after the
+// first on its own screen row after ContinuationPrompt.  Anything left over
+// from the previously printed line is blanked out.
+func (p *AbsPrompt) printLine() {
+	var runes, highlighter = p.displayLine()
+	var styles = highlightStyles(highlighter, runes, nil)
+	var prevRows = strings.Split(p.shown, "\n")
+	p.shown = string(runes)
+	var contWidth = VisualLength(p.ContinuationPrompt)
+
+	var row, start int
+	var wide bool
+	for i := 0; i <= len(runes); i++ {
+		if i < len(runes) && runes[i] != '\n' {
+			continue
+		}
+
+		var dx = p.promptWidth
+		if row > 0 {
+			p.printAtRow(row, 0, p.ContinuationPrompt)
+			dx = contWidth
+		}
+		p.buf.Reset()
+		writeStyledRunes(&p.buf, runes[start:i], styles[start:i])
+		var cells = RunesWidth(runes[start:i])
+		if cells != i-start {
+			wide = true
+		}
+		p.pos = i - start
+		if row < len(prevRows) {
+			if bigger := RunesWidth([]rune(prevRows[row])) - cells; bigger > 0 {
+				p.buf.WriteString(strings.Repeat(" ", bigger))
+				p.pos += bigger
+			}
+		}
+		p.printAtRow(row, dx, p.buf.String())
+
+		row++
+		start = i + 1
+	}
+
+	for ; row < len(prevRows); row++ {
+		p.printAtRow(row, 0, strings.Repeat(" ", contWidth+RunesWidth([]rune(prevRows[row]))))
+	}
+
+	// With multiple rows or characters which aren't one cell wide, where the
+	// cursor ended up isn't a line position
+	if row > 1 || len(prevRows) > 1 || wide {
+		p.pos = -1
+	}
+}
+
+// displayLine stores the current line and returns the runes which should be
+// drawn for it, along with the highlighter to use.  Masked input is drawn
+// without highlighting, which could give away what was typed.
+func (p *AbsPrompt) displayLine() ([]rune, Highlighter) {
+	p.m.RLock()
+	defer p.m.RUnlock()
+
+	var l, highlighter = p.Reader.line, p.Highlighter
+	p.line = l.String()
+	p.revealed = p.Reader.revealed
+	if p.Reader.masking(p.Mask) {
+		l, highlighter = p.Mask.apply(l), nil
+	}
+	l, highlighter = caretNotation(l, highlighter)
+	return append([]rune(nil), l.Text...), highlighter
 }
 
 // PrintCursorMovement sends the ANSI escape sequence for moving the cursor
 func (p *AbsPrompt) PrintCursorMovement() {
-	p.pos = p.Pos()
-	p.printAt(p.promptWidth+p.pos, "")
+	p.m.RLock()
+	var l = p.Reader.line
+	p.pos = l.Pos
+	if p.Reader.masking(p.Mask) {
+		l = p.Mask.apply(l)
+	}
+	l, _ = caretNotation(l, nil)
+	var row, col = l.CursorRow()
+	col = RunesWidth(l.Text[l.Pos-col : l.Pos])
+	p.m.RUnlock()
+
+	var dx = p.promptWidth
+	if row > 0 {
+		dx = VisualLength(p.ContinuationPrompt)
+	}
+	p.printAtRow(row, dx+col, "")
 }
@@ -0,0 +1,117 @@
+package terminal
+
+// maxArgument is the largest numeric argument, so a mistyped argument can't
+// keep the Reader busy repeating a key for ages
+const maxArgument = 9999
+
+// argumentKeypress builds up a numeric argument from Alt+digits and Alt+-.
+// Once an argument has been started, digits don't need Alt.  It returns true
+// if kp was part of the argument.
+func (r *Reader) argumentKeypress(kp Keypress) bool {
+	// lock has to be held here
+	var key = kp.Key
+	var digit = key >= '0' && key <= '9'
+	switch {
+	case kp.Modifier == ModAlt && key == '-':
+		if !r.argumentDigits {
+			r.argumentNegative = !r.argumentNegative
+		}
+	case kp.Modifier == ModAlt && digit, r.argumentActive && kp.Modifier == ModNone && digit:
+		r.argument = r.argument*10 + int(key-'0')
+		if r.argument > maxArgument {
+			r.argument = maxArgument
+		}
+		r.argumentDigits = true
+	default:
+		return false
+	}
+
+	r.argumentActive = true
+	return true
+}
+
+// takeArgument returns the numeric argument typed before the current key and
+// clears it.  ok is false if there was no argument.
+func (r *Reader) takeArgument() (n int, ok bool) {
+	// lock has to be held here
+	if !r.argumentActive {
+		return 1, false
+	}
+	n = r.argumentValue()
+	r.clearArgument()
+	return n, true
+}
+
+// argumentValue returns the numeric argument in progress, which is 1 (or -1)
+// when no digits have been typed
+func (r *Reader) argumentValue() int {
+	// lock has to be held here
+	var n = r.argument
+	if !r.argumentDigits {
+		n = 1
+	}
+	if r.argumentNegative {
+		n = -n
+	}
+	return n
+}
+
+// clearArgument throws away any numeric argument in progress
+func (r *Reader) clearArgument() {
+	// lock has to be held here
+	r.argument = 0
+	r.argumentActive = false
+	r.argumentDigits = false
+	r.argumentNegative = false
+}
+
+// NumericArgument returns the numeric argument the user is typing, if any,
+// so it can be displayed.  A lone Alt+- gives an argument of -1.
+func (r *Reader) NumericArgument() (n int, ok bool) {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	if !r.argumentActive {
+		return 0, false
+	}
+	return r.argumentValue(), true
+}
+
+// boundKey is a key and its modifier, without the raw bytes which make a
+// Keypress incomparable
+type boundKey struct {
+	key rune
+	mod KeyModifier
+}
+
+// oppositeKeys pairs up keys which do the opposite of each other, such as
+// moving left and right
+var oppositeKeys = [][2]boundKey{
+	{{KeyLeft, ModNone}, {KeyRight, ModNone}},
+	{{KeyBackspace, ModNone}, {KeyDelete, ModNone}},
+	{{KeyCtrlH, ModNone}, {KeyDelete, ModNone}},
+	{{KeyCtrlD, ModNone}, {KeyBackspace, ModNone}},
+	{{KeyCtrlU, ModNone}, {KeyCtrlK, ModNone}},
+	{{KeyCtrlW, ModNone}, {'d', ModAlt}},
+	{{KeyLeft, ModAlt}, {KeyRight, ModAlt}},
+	{{'b', ModAlt}, {'f', ModAlt}},
+	{{'B', ModAlt}, {'F', ModAlt}},
+	{{KeyBackspace, ModAlt}, {'d', ModAlt}},
+	{{KeyCtrlH, ModAlt}, {'d', ModAlt}},
+}
+
+// reverseKey returns the key which does the opposite of kp, such as moving
+// right instead of left, for use with a negative numeric argument.  Keys
+// without an opposite are returned as-is.
+func reverseKey(kp Keypress) Keypress {
+	var k = boundKey{kp.Key, kp.Modifier}
+	for _, pair := range oppositeKeys {
+		if k == pair[0] {
+			return Keypress{Key: pair[1].key, Modifier: pair[1].mod}
+		}
+		if k == pair[1] {
+			return Keypress{Key: pair[0].key, Modifier: pair[0].mod}
+		}
+	}
+	return kp
+}
@@ -0,0 +1,30 @@
+package terminal
+
+import "testing"
+
+func TestNumericArgument(t *testing.T) {
+	var r = NewReader(&MockReader{toSend: []byte("\x1b-\x1b4" + "2\x1b[D\r")})
+	var args []int
+	r.AfterKeypress = func(e *KeyEvent) {
+		var n, ok = r.argumentValue(), r.argumentActive
+		if ok {
+			args = append(args, n)
+		}
+	}
+	r.ReadLine()
+
+	if len(args) != 3 || args[0] != -1 || args[1] != -4 || args[2] != -42 {
+		t.Errorf("Expected arguments -1, -4, -42, got %v", args)
+	}
+	if _, ok := r.NumericArgument(); ok {
+		t.Errorf("Expected the argument to be used up")
+	}
+}
+
+func TestNumericArgumentCloseKey(t *testing.T) {
+	// CTRL+D after an argument is just a key to repeat, even on an empty line
+	var r = NewReader(&MockReader{toSend: []byte("\x1b4\x04ok\r")})
+	if line, err := r.ReadLine(); line != "ok" || err != nil {
+		t.Errorf("Expected %q, got %q (err %v)", "ok", line, err)
+	}
+}
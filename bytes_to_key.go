@@ -152,6 +152,23 @@ func ParseKey(b []byte, force bool) (r rune, rl int, mod KeyModifier) {
 		return keyUnknown(b, rl, force, mod)
 	}
 
+	// SGR mouse reports ("\x1b[<b;x;yM") are too long for the unknown-key
+	// parsing, so we look for their final byte here
+	if b[2] == '<' {
+		for i, c := range b[3:] {
+			if c == 'M' || c == 'm' {
+				return KeyMouse, rl + i + 4, mod
+			}
+			if (c < '0' || c > '9') && c != ';' {
+				return keyUnknown(b, rl, force, mod)
+			}
+		}
+		if force {
+			return utf8.RuneError, rl + len(b), mod
+		}
+		return utf8.RuneError, 0, ModNone
+	}
+
 	// Local terminal alt keys are sometimes longer sequences that come through
 	// as "\x1b[1;3" + some alpha
 	if l >= 6 && b[2] == '1' && b[3] == ';' && b[4] == '3' {
@@ -191,6 +208,12 @@ func ParseKey(b []byte, force bool) (r rune, rl int, mod KeyModifier) {
 		return KeyEnd, rl + 3, mod
 	case 'P':
 		return KeyPause, rl + 3, mod
+	case 'Z':
+		return KeyBackTab, rl + 3, mod
+	case 'I':
+		return KeyFocusIn, rl + 3, mod
+	case 'O':
+		return KeyFocusOut, rl + 3, mod
 	}
 
 	if l < 4 {
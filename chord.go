@@ -0,0 +1,351 @@
+package terminal
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrInvalidChord is returned by ParseChord and Reader.Bind when a chord
+// can't be used
+var ErrInvalidChord = errors.New("terminal: invalid chord")
+
+// ChordKey is a single key in a chord: a key and the modifier pressed with it
+type ChordKey struct {
+	Key      rune
+	Modifier KeyModifier
+}
+
+// chordKeyNames holds the emacs-style names of keys which can't be written
+// as a single character
+var chordKeyNames = map[rune]string{
+	KeyTab:       "TAB",
+	KeyEnter:     "RET",
+	KeyEscape:    "ESC",
+	' ':          "SPC",
+	KeyBackspace: "DEL",
+	KeyUp:        "<up>",
+	KeyDown:      "<down>",
+	KeyLeft:      "<left>",
+	KeyRight:     "<right>",
+	KeyHome:      "<home>",
+	KeyEnd:       "<end>",
+	KeyInsert:    "<insert>",
+	KeyDelete:    "<delete>",
+	KeyPgUp:      "<prior>",
+	KeyPgDn:      "<next>",
+	KeyF1:        "<f1>",
+	KeyF2:        "<f2>",
+	KeyF3:        "<f3>",
+	KeyF4:        "<f4>",
+	KeyF5:        "<f5>",
+	KeyF6:        "<f6>",
+	KeyF7:        "<f7>",
+	KeyF8:        "<f8>",
+	KeyF9:        "<f9>",
+	KeyF10:       "<f10>",
+	KeyF11:       "<f11>",
+	KeyF12:       "<f12>",
+	KeyBackTab:   "<backtab>",
+}
+
+// String returns k in emacs-style notation, such as "C-x", "M-f" or "RET".
+// Alt is shown as "M-" and Meta as "s-", since the sequence terminals send
+// for Meta is the one emacs uses to add the super modifier.
+func (k ChordKey) String() string {
+	var prefix string
+	if k.Modifier&ModMeta != 0 {
+		prefix += "s-"
+	}
+	if k.Modifier&ModAlt != 0 {
+		prefix += "M-"
+	}
+
+	if name, ok := chordKeyNames[k.Key]; ok {
+		return prefix + name
+	}
+	if k.Key < ' ' {
+		return prefix + "C-" + strings.ToLower(string(k.Key+'@'))
+	}
+	if !isPrintable(k.Key) {
+		return prefix + "<unknown>"
+	}
+	return prefix + string(k.Key)
+}
+
+// ParseChord turns emacs-style notation, such as "C-x C-e" or "g g", into
+// the keys of a chord.  Each key is separated by spaces, and may have any of
+// the prefixes "C-" (control), "M-" (Alt) and "s-" (Meta).  Keys which can't
+// be typed as a single character use the names ChordKey.String gives them.
+func ParseChord(s string) ([]ChordKey, error) {
+	var keys []ChordKey
+	for _, field := range strings.Fields(s) {
+		var k, ok = parseChordKey(field)
+		if !ok {
+			return nil, ErrInvalidChord
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return nil, ErrInvalidChord
+	}
+	return keys, nil
+}
+
+// parseChordKey parses a single key for ParseChord
+func parseChordKey(s string) (k ChordKey, ok bool) {
+	var ctrl bool
+	for len(s) > 2 && s[1] == '-' {
+		switch s[0] {
+		case 'C':
+			ctrl = true
+		case 'M':
+			k.Modifier |= ModAlt
+		case 's':
+			k.Modifier |= ModMeta
+		default:
+			return k, false
+		}
+		s = s[2:]
+	}
+
+	k.Key = -1
+	for key, name := range chordKeyNames {
+		if name == s {
+			k.Key = key
+		}
+	}
+	if k.Key < 0 {
+		var runes = []rune(s)
+		if len(runes) != 1 {
+			return k, false
+		}
+		k.Key = runes[0]
+	}
+
+	if ctrl {
+		switch {
+		case k.Key == ' ' || k.Key == '@':
+			k.Key = KeyCtrlSpace
+		case k.Key >= 'a' && k.Key <= 'z', k.Key >= '[' && k.Key <= '_':
+			k.Key &= 0x1f
+		default:
+			return k, false
+		}
+	}
+	return k, true
+}
+
+// chordBinding is a chord and the function it runs
+type chordBinding struct {
+	keys []ChordKey
+	fn   func(e *KeyEvent)
+}
+
+// Bind makes fn run when the keys in chord are typed one after another, such
+// as the keys ParseChord returns for "C-x C-e".  While a chord is partly
+// typed, its keys are held back, and PendingChord describes them.  If the
+// next key doesn't continue any chord, or ChordTimeout passes first, the keys
+// are handled as usual.  Binding a chord again replaces its function.
+//
+// A chord which has been typed in full runs right away, so it shouldn't also
+// start a longer chord.  Only the last key can be Enter, since handling a held
+// key as usual mustn't submit the line.  Keys handled by ReadLine itself, such
+// as InterruptKey and CloseKey, never reach the chord dispatcher.
+//
+// A numeric argument typed before the chord is passed to fn in e.Argument.
+// As with OnKeypress, fn is called with the Reader locked, so it shouldn't
+// call Reader methods like SetLine, and should change e.Line instead.
+func (r *Reader) Bind(chord []ChordKey, fn func(e *KeyEvent)) error {
+	if len(chord) == 0 || fn == nil {
+		return ErrInvalidChord
+	}
+	for _, k := range chord[:len(chord)-1] {
+		if k.Key == KeyEnter {
+			return ErrInvalidChord
+		}
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.bind(chord, fn)
+	return nil
+}
+
+// bind implements Bind
+func (r *Reader) bind(chord []ChordKey, fn func(e *KeyEvent)) {
+	// lock has to be held here
+	r.unbind(chord)
+	r.chords = append(r.chords, chordBinding{keys: append([]ChordKey(nil), chord...), fn: fn})
+}
+
+// Unbind removes the binding for chord, if any, including the built-in CTRL+X
+// chords
+func (r *Reader) Unbind(chord []ChordKey) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.unbind(chord)
+}
+
+// unbind implements Unbind
+func (r *Reader) unbind(chord []ChordKey) {
+	// lock has to be held here
+	for i, b := range r.chords {
+		if len(b.keys) == len(chord) && chordHasPrefix(b.keys, chord) {
+			r.chords = append(r.chords[:i], r.chords[i+1:]...)
+			return
+		}
+	}
+}
+
+// bindDefaultChords sets up the Reader's built-in CTRL+X chords
+func (r *Reader) bindDefaultChords() {
+	// lock has to be held here
+	var ctrlX = ChordKey{Key: KeyCtrlX}
+	r.bind([]ChordKey{ctrlX, {Key: KeyCtrlX}}, func(e *KeyEvent) { e.Line.ExchangeMark() })
+	r.bind([]ChordKey{ctrlX, {Key: '('}}, func(e *KeyEvent) { r.startMacro() })
+	r.bind([]ChordKey{ctrlX, {Key: ')'}}, func(e *KeyEvent) { r.stopMacro() })
+	var playMacro = func(e *KeyEvent) { r.queueMacro(e.Argument) }
+	r.bind([]ChordKey{ctrlX, {Key: 'e'}}, playMacro)
+	r.bind([]ChordKey{ctrlX, {Key: 'E'}}, playMacro)
+}
+
+// chordHasPrefix returns true if keys starts with the keypresses in prefix
+func chordHasPrefix(keys, prefix []ChordKey) bool {
+	if len(prefix) > len(keys) {
+		return false
+	}
+	for i, k := range prefix {
+		if keys[i] != k {
+			return false
+		}
+	}
+	return true
+}
+
+// matchChord returns the binding typed in full by keys, if any, and whether
+// keys are the start of a longer chord
+func (r *Reader) matchChord(keys []Keypress) (binding *chordBinding, prefix bool) {
+	// lock has to be held here
+	var typed = make([]ChordKey, len(keys))
+	for i, kp := range keys {
+		typed[i] = ChordKey{Key: kp.Key, Modifier: kp.Modifier}
+	}
+	for i, b := range r.chords {
+		if !chordHasPrefix(b.keys, typed) {
+			continue
+		}
+		if len(b.keys) == len(typed) {
+			return &r.chords[i], false
+		}
+		prefix = true
+	}
+	return nil, prefix
+}
+
+// dispatchChord adds kp to any held keys and either runs the chord they
+// complete, holds them for the next key, or handles them as usual if they
+// aren't part of a chord
+func (r *Reader) dispatchChord(kp Keypress) (line string, ok bool) {
+	// lock has to be held here
+	kp.Raw = append([]byte(nil), kp.Raw...)
+	var keys = append(r.chordKeys, kp)
+	r.clearChord()
+
+	// When the held keys turn out not to be a chord, the first is handled as
+	// usual and the rest are tried again, since they may start a chord
+	for len(keys) > 0 && !ok {
+		var binding, prefix = r.matchChord(keys)
+		switch {
+		case binding != nil:
+			r.runChord(binding, keys[len(keys)-1])
+			return
+		case prefix:
+			r.holdChord(keys)
+			return
+		}
+		line, ok = r.handleKey(keys[0])
+		keys = keys[1:]
+	}
+	return
+}
+
+// runChord calls binding's function for a chord ending in kp
+func (r *Reader) runChord(binding *chordBinding, kp Keypress) {
+	// lock has to be held here
+	var e = &KeyEvent{Keypress: kp, Line: r.line}
+	e.Argument, e.HasArgument = r.takeArgument()
+	binding.fn(e)
+	r.completion = nil
+	r.clearValidation()
+	r.updateSuggestion()
+	if r.AfterKeypress != nil {
+		r.AfterKeypress(e)
+	}
+}
+
+// holdChord keeps keys back until the next key or a timeout shows whether
+// they're part of a chord
+func (r *Reader) holdChord(keys []Keypress) {
+	// lock has to be held here
+	r.chordKeys = keys
+	if r.ChordTimeout > 0 {
+		var gen = r.chordGen
+		r.chordTimer = time.AfterFunc(r.ChordTimeout, func() { r.chordTimedOut(gen) })
+	}
+	if r.AfterKeypress != nil {
+		r.AfterKeypress(&KeyEvent{Keypress: keys[len(keys)-1], Line: r.line})
+	}
+}
+
+// chordTimedOut handles held keys as usual once ChordTimeout has passed
+// without another key.  gen says which keys were held, in case more keys
+// came in as the timer went off.
+func (r *Reader) chordTimedOut(gen int) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if gen != r.chordGen {
+		return
+	}
+	var keys = r.chordKeys
+	r.chordKeys = nil
+	r.chordTimer = nil
+	for _, kp := range keys {
+		r.handleKey(kp)
+	}
+}
+
+// clearChord throws away any held keys
+func (r *Reader) clearChord() {
+	// lock has to be held here
+	r.chordGen++
+	r.chordKeys = nil
+	if r.chordTimer != nil {
+		r.chordTimer.Stop()
+		r.chordTimer = nil
+	}
+}
+
+// pendingChord describes the held keys the way emacs does, such as "C-x-",
+// or returns an empty string if there are none
+func (r *Reader) pendingChord() string {
+	// lock has to be held here
+	if len(r.chordKeys) == 0 {
+		return ""
+	}
+	var names = make([]string, len(r.chordKeys))
+	for i, kp := range r.chordKeys {
+		names[i] = ChordKey{Key: kp.Key, Modifier: kp.Modifier}.String()
+	}
+	return strings.Join(names, " ") + "-"
+}
+
+// PendingChord returns the start of a chord which has been typed, such as
+// "C-x-", so it can be displayed while the Reader waits for the rest.  It
+// returns an empty string when no chord is in progress.
+func (r *Reader) PendingChord() string {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	return r.pendingChord()
+}
@@ -0,0 +1,156 @@
+package terminal
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseChord(t *testing.T) {
+	var tests = []struct {
+		in       string
+		expected []ChordKey
+	}{
+		{"C-x C-e", []ChordKey{{Key: KeyCtrlX}, {Key: KeyCtrlE}}},
+		{"g g", []ChordKey{{Key: 'g'}, {Key: 'g'}}},
+		{"M-f", []ChordKey{{Key: 'f', Modifier: ModAlt}}},
+		{"C-M-a", []ChordKey{{Key: KeyCtrlA, Modifier: ModAlt}}},
+		{"C-SPC RET", []ChordKey{{Key: KeyCtrlSpace}, {Key: KeyEnter}}},
+		{"s-<f1>", []ChordKey{{Key: KeyF1, Modifier: ModMeta}}},
+		{"C-1", nil},
+		{"ab", nil},
+		{"", nil},
+	}
+
+	for _, test := range tests {
+		var keys, err = ParseChord(test.in)
+		if test.expected == nil {
+			if err != ErrInvalidChord {
+				t.Errorf("Expected %q to be invalid, got %v", test.in, keys)
+			}
+			continue
+		}
+		if err != nil || len(keys) != len(test.expected) || !chordHasPrefix(keys, test.expected) {
+			t.Errorf("Expected %q to give %v, got %v (err %v)", test.in, test.expected, keys, err)
+		}
+	}
+}
+
+func TestChordKeyString(t *testing.T) {
+	var tests = map[ChordKey]string{
+		{Key: KeyCtrlX}:                  "C-x",
+		{Key: KeyCtrlSpace}:              "C-@",
+		{Key: KeyTab}:                    "TAB",
+		{Key: 'g'}:                       "g",
+		{Key: 'f', Modifier: ModAlt}:     "M-f",
+		{Key: KeyLeft, Modifier: ModAlt}: "M-<left>",
+	}
+	for k, expected := range tests {
+		if k.String() != expected {
+			t.Errorf("Expected %q, got %q", expected, k.String())
+		}
+	}
+}
+
+func mustParseChord(t *testing.T, s string) []ChordKey {
+	var keys, err = ParseChord(s)
+	if err != nil {
+		t.Fatalf("Unable to parse %q: %s", s, err)
+	}
+	return keys
+}
+
+func TestBind(t *testing.T) {
+	var r = NewReader(&MockReader{toSend: []byte("ab\x18\x05gxgg\x1b-xy\r")})
+	r.Bind(mustParseChord(t, "C-x C-e"), func(e *KeyEvent) {
+		e.Line.Set([]rune("edited"), 6)
+	})
+	r.Bind(mustParseChord(t, "g g"), func(e *KeyEvent) {
+		e.Line.MoveHome()
+	})
+	var arg int
+	var hasArg bool
+	r.Bind(mustParseChord(t, "x y"), func(e *KeyEvent) {
+		e.Line.AddKeyToLine('!')
+		arg, hasArg = e.Argument, e.HasArgument
+	})
+
+	// "g x" isn't a chord, so it's typed as usual, and the numeric argument
+	// goes to the chord after it rather than repeating a key
+	var line, _ = r.ReadLine()
+	if line != "!editedgx" {
+		t.Errorf("Expected %q, got %q", "!editedgx", line)
+	}
+	if arg != -1 || !hasArg {
+		t.Errorf("Expected the chord to get an argument of -1, got %d (%v)", arg, hasArg)
+	}
+
+	// The held keys are passed through as usual when the next key doesn't
+	// continue a chord, even if the first could start another one
+	r = NewReader(&MockReader{toSend: []byte("gxy\r")})
+	r.Bind(mustParseChord(t, "g g"), func(e *KeyEvent) {})
+	r.Bind(mustParseChord(t, "x y"), func(e *KeyEvent) { e.Line.AddKeyToLine('!') })
+	if line, _ = r.ReadLine(); line != "g!" {
+		t.Errorf("Expected %q, got %q", "g!", line)
+	}
+
+	if r.Bind(mustParseChord(t, "RET x"), func(e *KeyEvent) {}) != ErrInvalidChord {
+		t.Errorf("Expected Enter to be refused as the start of a chord")
+	}
+}
+
+func TestUnbind(t *testing.T) {
+	var r = NewReader(&MockReader{toSend: []byte("ab\x00cd\x18\x18X\r")})
+	r.Unbind(mustParseChord(t, "C-x C-x"))
+	if line, _ := r.ReadLine(); line != "abcdX" {
+		t.Errorf("Expected the unbound chord to do nothing, got %q", line)
+	}
+}
+
+func TestChordTimeout(t *testing.T) {
+	var pr, pw = io.Pipe()
+	var r = NewReader(pr)
+	r.ChordTimeout = 10 * time.Millisecond
+	r.Bind(mustParseChord(t, "g g"), func(e *KeyEvent) {})
+
+	var keys = make(chan rune, 10)
+	var pending = make(chan string, 10)
+	r.AfterKeypress = func(e *KeyEvent) {
+		pending <- r.pendingChord()
+		keys <- e.Key
+	}
+
+	var done = make(chan string)
+	go func() {
+		var line, _ = r.ReadLine()
+		done <- line
+	}()
+
+	pw.Write([]byte("g"))
+	<-keys
+	if p := <-pending; p != "g-" {
+		t.Errorf("Expected %q to be pending, got %q", "g-", p)
+	}
+
+	// Once the timeout passes, the held key goes into the line
+	<-keys
+	if p := <-pending; p != "" {
+		t.Errorf("Expected nothing to be pending, got %q", p)
+	}
+	pw.Write([]byte("\r"))
+	if line := <-done; line != "g" {
+		t.Errorf("Expected %q, got %q", "g", line)
+	}
+}
+
+func TestPromptPendingChord(t *testing.T) {
+	var out bytes.Buffer
+	var p = NewPrompt(&MockReader{toSend: []byte("\x18\x18\r")}, &out, "> ")
+	p.ReadLine()
+
+	if !strings.Contains(out.String(), "\r\n\x1b[J\x1b[2mC-x-\x1b[0m") {
+		t.Errorf("Expected the pending chord to be drawn, got %q", out.String())
+	}
+}
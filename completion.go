@@ -0,0 +1,153 @@
+package terminal
+
+// A Completer offers tab-completion candidates for a Reader.  Complete is
+// given the full line and the cursor position (in runes), and returns the
+// possible completions along with the range of runes, start up to (but not
+// including) end, which a candidate should replace.  Candidates are inserted
+// verbatim, so a completer which wants a space after a completed word should
+// include it in the candidate.
+type Completer interface {
+	Complete(line string, pos int) (candidates []string, start, end int)
+}
+
+// CompleterFunc is an adapter to allow the use of an ordinary function as a
+// Completer
+type CompleterFunc func(line string, pos int) (candidates []string, start, end int)
+
+// Complete calls f(line, pos)
+func (f CompleterFunc) Complete(line string, pos int) ([]string, int, int) {
+	return f(line, pos)
+}
+
+// completion stores the state of a tab completion which is in progress
+type completion struct {
+	candidates []string
+
+	// descriptions holds each candidate's description if the Completer is a
+	// Describer, and is nil otherwise
+	descriptions []string
+
+	// start and end are the range of runes in the line currently occupied by
+	// the completed text
+	start, end int
+
+	// original holds the text which was there before completion started
+	original []rune
+
+	// selected is the index of the candidate in the line, or -1 if we haven't
+	// started cycling through candidates
+	selected int
+
+	// columns is set by whatever is drawing a completion menu, telling us how
+	// many candidates are on each row.  When it's zero, there's no menu, so the
+	// arrow keys aren't used for navigating the candidates.
+	columns int
+}
+
+// longestCommonPrefix returns the longest string which all candidates start
+// with, in runes
+func longestCommonPrefix(candidates []string) []rune {
+	var prefix = []rune(candidates[0])
+	for _, c := range candidates[1:] {
+		var i int
+		for _, r := range c {
+			if i == len(prefix) || prefix[i] != r {
+				break
+			}
+			i++
+		}
+		prefix = prefix[:i]
+	}
+	return prefix
+}
+
+// complete handles a tab (or shift-tab, if backward is true) keypress.  The
+// first tab fetches candidates from the Completer: a single candidate is used
+// immediately, while multiple candidates have their longest common prefix
+// inserted.  Tabbing again cycles through the candidates.
+func (r *Reader) complete(backward bool) {
+	// lock has to be held here
+	if r.completion == nil {
+		var c = r.startCompletion()
+		if c != nil && len(c.candidates) > 1 {
+			r.replaceCompletion(c, longestCommonPrefix(c.candidates))
+			r.completion = c
+		}
+		return
+	}
+
+	var c = r.completion
+	var next = c.selected + 1
+	if backward {
+		next = c.selected - 1
+		if next < 0 {
+			next = len(c.candidates) - 1
+		}
+	}
+	next %= len(c.candidates)
+	if r.replaceCompletion(c, []rune(c.candidates[next])) {
+		c.selected = next
+	}
+}
+
+// startCompletion asks the Completer for candidates, returning nil if there
+// aren't any.  If there's exactly one, it's put into the line.
+func (r *Reader) startCompletion() *completion {
+	var candidates, start, end = r.Completer.Complete(r.line.String(), r.line.Pos)
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	var l = len(r.line.Text)
+	if end > l {
+		end = l
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > end {
+		start = end
+	}
+
+	var c = &completion{
+		candidates:   candidates,
+		descriptions: describe(r.Completer, candidates),
+		start:        start,
+		end:          end,
+		original:     append([]rune(nil), r.line.Text[start:end]...),
+		selected:     -1,
+	}
+	if len(candidates) == 1 {
+		r.replaceCompletion(c, []rune(candidates[0]))
+	}
+	return c
+}
+
+// replaceCompletion puts text in place of the completion's current text,
+// returning false if the line would be too long
+func (r *Reader) replaceCompletion(c *completion, text []rune) bool {
+	var current = r.line.Text[c.start:c.end]
+	if string(current) == string(text) {
+		return true
+	}
+	if len(r.line.Text)-len(current)+len(text) > r.MaxLineLength {
+		return false
+	}
+
+	r.line.Replace(c.start, c.end, text)
+	c.end = c.start + len(text)
+	return true
+}
+
+// Completions returns the candidates of the tab completion in progress, if
+// any, and the index of the candidate currently in the line, or -1 if the
+// user hasn't started cycling through them.  This is meant for displaying the
+// list of candidates to the user.
+func (r *Reader) Completions() (candidates []string, selected int) {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	if r.completion == nil {
+		return nil, -1
+	}
+	return r.completion.candidates, r.completion.selected
+}
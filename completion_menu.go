@@ -0,0 +1,252 @@
+package terminal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A Describer is a Completer which can also offer a short description of each
+// candidate, to be displayed next to the candidate in a completion menu
+type Describer interface {
+	Describe(candidate string) string
+}
+
+// CompletionMenu lays out the candidates of an in-progress tab completion in
+// columns for display below the input line.  It's used by Prompt and
+// AbsPrompt, but can be used directly by anything else rendering a Reader.
+type CompletionMenu struct {
+	// Width is the number of columns the menu may use
+	Width int
+
+	// MaxRows is the most rows the menu will take up.  If there are too many
+	// candidates to fit, the menu is paged, with the last row showing which
+	// rows are visible.  Zero means no limit.
+	MaxRows int
+
+	// SelectedStyle and DescriptionStyle are SGR parameters (e.g., "1;33" for
+	// bold yellow) used for highlighting the selected candidate and for drawing
+	// descriptions, respectively
+	SelectedStyle    string
+	DescriptionStyle string
+}
+
+// NewCompletionMenu returns a CompletionMenu set up for an 80-column terminal,
+// showing up to ten rows
+func NewCompletionMenu() *CompletionMenu {
+	return &CompletionMenu{
+		Width:            80,
+		MaxRows:          10,
+		SelectedStyle:    "7",
+		DescriptionStyle: "2",
+	}
+}
+
+// sgr returns the escape sequence for the given SGR parameters
+func sgr(params string) string {
+	return "\x1b[" + params + "m"
+}
+
+// sgrReset turns off all SGR attributes
+const sgrReset = "\x1b[0m"
+
+// Layout returns the menu rows for the given candidates, with the candidate
+// at index selected highlighted (if selected isn't -1), along with the number
+// of candidates in each row.  Every row is padded to exactly Width columns so
+// that drawing it completely overwrites whatever was there before.
+// descriptions may be nil; if not, it must be the same length as candidates,
+// and each candidate gets its own row.
+func (m *CompletionMenu) Layout(candidates, descriptions []string, selected int) (rows []string, columns int) {
+	var n = len(candidates)
+	if n == 0 || m.Width < 1 {
+		return nil, 0
+	}
+
+	var colWidth int
+	for i, c := range candidates {
+		var w = VisualLength(c)
+		if descriptions != nil && descriptions[i] != "" {
+			w += 2 + VisualLength(descriptions[i])
+		}
+		if w > colWidth {
+			colWidth = w
+		}
+	}
+	colWidth += 2
+	if colWidth > m.Width {
+		colWidth = m.Width
+	}
+
+	columns = m.Width / colWidth
+	if descriptions != nil {
+		columns = 1
+	}
+	var totalRows = (n + columns - 1) / columns
+
+	var pageRows = totalRows
+	var paged = m.MaxRows > 0 && totalRows > m.MaxRows
+	if paged {
+		pageRows = m.MaxRows - 1
+		if pageRows < 1 {
+			pageRows = 1
+		}
+	}
+
+	var first = 0
+	if selected >= 0 {
+		first = (selected / columns) / pageRows * pageRows
+	}
+	var last = first + pageRows
+	if last > totalRows {
+		last = totalRows
+	}
+
+	for row := first; row < last; row++ {
+		var b strings.Builder
+		var used int
+		for col := 0; col < columns; col++ {
+			var i = row*columns + col
+			if i >= n {
+				break
+			}
+			var desc string
+			if descriptions != nil {
+				desc = descriptions[i]
+			}
+			used += m.writeItem(&b, candidates[i], desc, i == selected, colWidth)
+		}
+		b.WriteString(strings.Repeat(" ", m.Width-used))
+		rows = append(rows, b.String())
+	}
+
+	if paged {
+		var status = fitText(fmt.Sprintf("rows %d-%d of %d", first+1, last, totalRows), m.Width)
+		rows = append(rows, sgr(m.DescriptionStyle)+status+sgrReset+strings.Repeat(" ", m.Width-VisualLength(status)))
+	}
+
+	return rows, columns
+}
+
+// writeItem draws a single candidate into a cell of the given width,
+// returning the number of columns used
+func (m *CompletionMenu) writeItem(b *strings.Builder, candidate, desc string, selected bool, width int) int {
+	var text = fitText(candidate, width-2)
+	if selected {
+		b.WriteString(sgr(m.SelectedStyle))
+		b.WriteString(text)
+		b.WriteString(sgrReset)
+	} else {
+		b.WriteString(text)
+	}
+	var used = VisualLength(text)
+
+	var room = width - 2 - used - 2
+	if desc != "" && room > 0 {
+		desc = fitText(desc, room)
+		b.WriteString("  ")
+		b.WriteString(sgr(m.DescriptionStyle))
+		b.WriteString(desc)
+		b.WriteString(sgrReset)
+		used += 2 + VisualLength(desc)
+	}
+
+	b.WriteString(strings.Repeat(" ", width-used))
+	return width
+}
+
+// fitText truncates s to fit in width columns, replacing the last visible
+// character with an ellipsis if anything was cut off
+func fitText(s string, width int) string {
+	if width < 1 {
+		return ""
+	}
+	var runes = []rune(s)
+	if RunesWidth(runes) <= width {
+		return s
+	}
+
+	var end, cells = 0, 0
+	for end < len(runes) && cells+RuneWidth(runes[end]) <= width-1 {
+		cells += RuneWidth(runes[end])
+		end++
+	}
+	return string(runes[:end]) + "…"
+}
+
+// describe returns descriptions for each candidate if c is a Describer, or
+// nil otherwise
+func describe(c Completer, candidates []string) []string {
+	var d, ok = c.(Describer)
+	if !ok {
+		return nil
+	}
+
+	var descriptions = make([]string, len(candidates))
+	for i, candidate := range candidates {
+		descriptions[i] = d.Describe(candidate)
+	}
+	return descriptions
+}
+
+// completionMenu returns the rows of m for the completion in progress, or nil
+// if there isn't one.  The number of columns is stored so the arrow keys can
+// navigate the menu.
+func (r *Reader) completionMenu(m *CompletionMenu) []string {
+	// lock has to be held here
+	var c = r.completion
+	if m == nil || c == nil {
+		return nil
+	}
+
+	var rows, columns = m.Layout(c.candidates, c.descriptions, c.selected)
+	c.columns = columns
+	return rows
+}
+
+// menuKeypress handles keys while a completion menu is on screen: arrows move
+// the selection, enter accepts the selected candidate, and escape (or CTRL+G)
+// puts back what the user had typed.  The return is false if the key should
+// be handled normally instead, which also ends the completion.
+func (r *Reader) menuKeypress(kp Keypress) bool {
+	// lock has to be held here
+	var c = r.completion
+	if kp.Modifier != ModNone {
+		return false
+	}
+
+	var next = c.selected
+	switch kp.Key {
+	case KeyEnter:
+		r.completion = nil
+		return true
+	case KeyEscape, KeyCtrlG:
+		r.replaceCompletion(c, c.original)
+		r.completion = nil
+		return true
+	case KeyRight:
+		next++
+	case KeyLeft:
+		next--
+	case KeyDown:
+		if next < 0 {
+			next = 0
+		} else {
+			next += c.columns
+		}
+	case KeyUp:
+		next -= c.columns
+	default:
+		return false
+	}
+
+	var n = len(c.candidates)
+	if next < 0 {
+		next = n - 1
+	}
+	if next >= n {
+		next = 0
+	}
+	if r.replaceCompletion(c, []rune(c.candidates[next])) {
+		c.selected = next
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package terminal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCompletionMenuLayout(t *testing.T) {
+	var m = NewCompletionMenu()
+	m.Width = 20
+	m.MaxRows = 3
+	m.SelectedStyle = "7"
+
+	var candidates = []string{"alpha", "beta", "gamma", "delta", "epsilon", "zeta", "eta", "theta"}
+	var rows, columns = m.Layout(candidates, nil, -1)
+	if columns != 2 {
+		t.Fatalf("Expected 2 columns of 9-wide items, got %d", columns)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("Expected two rows plus a status row, got %q", rows)
+	}
+	if rows[0] != "alpha    beta       " {
+		t.Errorf("Unexpected first row %q", rows[0])
+	}
+	if !strings.Contains(rows[2], "rows 1-2 of 4") {
+		t.Errorf("Expected status row, got %q", rows[2])
+	}
+	for i, row := range rows {
+		if VisualLength(row) != m.Width {
+			t.Errorf("Row %d should be %d wide, but is %d", i, m.Width, VisualLength(row))
+		}
+	}
+
+	// Selecting something on the second page should show that page
+	rows, _ = m.Layout(candidates, nil, 6)
+	if rows[0] != "epsilon  zeta       " {
+		t.Errorf("Unexpected first row on page two: %q", rows[0])
+	}
+	if !strings.Contains(rows[1], "\x1b[7meta\x1b[0m") {
+		t.Errorf("Expected selected item to be highlighted, got %q", rows[1])
+	}
+
+	// Descriptions put each item on its own row
+	rows, columns = m.Layout([]string{"a", "bb"}, []string{"first", ""}, -1)
+	if columns != 1 || len(rows) != 2 {
+		t.Fatalf("Expected one column and two rows, got %d and %q", columns, rows)
+	}
+	if rows[0] != "a  \x1b[2mfirst\x1b[0m            " {
+		t.Errorf("Unexpected described row %q", rows[0])
+	}
+}
+
+var menuTests = []struct {
+	in   string
+	line string
+}{
+	{
+		// arrows select, enter accepts without submitting the line
+		in:   "say b\t\x1b[C\x1b[C\r\r",
+		line: "say baz",
+	},
+	{
+		in:   "say b\t\x1b[D\r\r",
+		line: "say baz",
+	},
+	{
+		// CTRL+G cancels, restoring the original text
+		in:   "say b\t\x1b[C\a\r",
+		line: "say b",
+	},
+	{
+		// other keys end the menu and act normally
+		in:   "say b\t\x1b[Cx\r",
+		line: "say barx",
+	},
+}
+
+func TestCompletionMenuKeys(t *testing.T) {
+	var completer = CompleterFunc(func(line string, pos int) ([]string, int, int) {
+		return []string{"bar", "baz"}, 4, pos
+	})
+
+	for i, test := range menuTests {
+		var out bytes.Buffer
+		var p = NewPrompt(&MockReader{toSend: []byte(test.in)}, &out, "> ")
+		p.Completer = completer
+		line, _ := p.ReadLine()
+		if line != test.line {
+			t.Errorf("Line resulting from test %d was '%s', expected '%s'", i, line, test.line)
+		}
+		if !strings.Contains(out.String(), "bar  baz") {
+			t.Errorf("Expected menu to be drawn in test %d, got %q", i, out.String())
+		}
+		var erased = strings.LastIndex(out.String(), "\r\n\x1b[J\x1b[1A\r")
+		if erased < strings.LastIndex(out.String(), "baz ") {
+			t.Errorf("Expected menu to be erased in test %d, got %q", i, out.String())
+		}
+	}
+}
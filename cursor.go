@@ -0,0 +1,57 @@
+package terminal
+
+import (
+	"io"
+	"strconv"
+)
+
+// CursorShape is one of the cursor shapes terminals can be asked to use with
+// the DECSCUSR sequence
+type CursorShape int
+
+// CursorShape values, which match the DECSCUSR parameters
+const (
+	CursorDefault CursorShape = iota
+	CursorBlinkingBlock
+	CursorBlock
+	CursorBlinkingUnderline
+	CursorUnderline
+	CursorBlinkingBar
+	CursorBar
+)
+
+// cursorUnknown means the cursor's shape hasn't been set, so it has to be set
+// before it can be relied on
+const cursorUnknown CursorShape = -1
+
+// CursorStyle tells a Prompt or AbsPrompt to change the shape of the cursor
+// to show whether the Reader is in overwrite mode
+type CursorStyle struct {
+	// Insert and Overwrite are the shapes used in each mode
+	Insert    CursorShape
+	Overwrite CursorShape
+
+	// Restore is the shape set once ReadLine returns.  It defaults to
+	// CursorDefault, which is whatever shape the user's terminal is set up to
+	// use.
+	Restore CursorShape
+}
+
+// NewCursorStyle returns a CursorStyle which uses a bar for insert mode and a
+// block for overwrite mode
+func NewCursorStyle() *CursorStyle {
+	return &CursorStyle{Insert: CursorBar, Overwrite: CursorBlock}
+}
+
+// shape returns the shape to use in the given mode
+func (cs *CursorStyle) shape(overwrite bool) CursorShape {
+	if overwrite {
+		return cs.Overwrite
+	}
+	return cs.Insert
+}
+
+// writeShape writes the DECSCUSR sequence for shape to w
+func writeShape(w io.Writer, shape CursorShape) {
+	io.WriteString(w, "\x1b["+strconv.Itoa(int(shape))+" q")
+}
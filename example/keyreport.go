@@ -65,6 +65,7 @@ var keyText = map[rune]string{
 	terminal.KeyF10:          "KeyF10",
 	terminal.KeyF11:          "KeyF11",
 	terminal.KeyF12:          "KeyF12",
+	terminal.KeyBackTab:      "KeyBackTab",
 }
 
 var done bool
@@ -35,6 +35,9 @@ func main() {
 
 	for {
 		var guess, err = p.ReadLine()
+		if err == terminal.ErrInterrupt {
+			continue
+		}
 		if err != nil {
 			fmt.Print("\r\nOh no, I got an error!\r\n")
 			fmt.Printf("%s\r\n", err)
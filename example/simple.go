@@ -28,29 +28,6 @@ func onKeypress(e *terminal.KeyEvent) {
 		e.IgnoreDefaultHandlers = true
 	}
 
-	// Tab-complete example: matches foobar and autocompletes it if:
-	// - At least two characters have been entered
-	// - All entered characters are prefix of "foobar"
-	// - Cursor is at the end of the line
-	// - Current line hasn't already printed out "foobar"
-	if e.Key == 0x09 {
-		var runes = []rune("foobar")
-		if len(e.Line.Text) < 2 || len(e.Line.Text) >= len(runes) || e.Line.Pos != len(e.Line.Text) {
-			return
-		}
-
-		for i, r := range e.Line.Text {
-			if r != runes[i] {
-				return
-			}
-		}
-
-		e.Line.Text = runes
-		e.Line.Pos = len(runes)
-		e.IgnoreDefaultHandlers = true
-		return
-	}
-
 	// Modifications to entire line based on use of Page Up key
 	if e.Key == terminal.KeyPgUp && e.Modifier == terminal.ModNone {
 		for i, r := range e.Line.Text {
@@ -71,6 +48,17 @@ func onKeypress(e *terminal.KeyEvent) {
 	}
 }
 
+// completeFoobar is a tab-completion example: it offers "foobar" if at least
+// two characters have been typed, all of which are a prefix of "foobar", and
+// the cursor is at the end of the line
+func completeFoobar(line string, pos int) ([]string, int, int) {
+	var runes = []rune(line)
+	if len(runes) < 2 || pos != len(runes) || !strings.HasPrefix("foobar", line) {
+		return nil, 0, 0
+	}
+	return []string{"foobar"}, 0, pos
+}
+
 func printAt(x, y int, output string) {
 	fmt.Fprintf(os.Stdout, "%s%d;%dH%s", CSI, y, x, output)
 }
@@ -128,6 +116,7 @@ func main() {
 	t = terminal.NewReader(os.Stdin)
 	t.MaxLineLength = 70
 	t.OnKeypress = onKeypress
+	t.Completer = terminal.CompleterFunc(completeFoobar)
 	go readInput()
 	go printOutput()
 
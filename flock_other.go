@@ -0,0 +1,15 @@
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package terminal
+
+import "os"
+
+// lockFile is a no-op on systems without flock(2)
+func lockFile(f *os.File, exclusive bool) error {
+	return nil
+}
+
+// unlockFile is a no-op on systems without flock(2)
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,28 @@
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package terminal
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an advisory lock on f, blocking until it's available
+func lockFile(f *os.File, exclusive bool) error {
+	var how = syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+
+	for {
+		var err = syscall.Flock(int(f.Fd()), how)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
+
+// unlockFile releases the lock taken by lockFile
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,180 @@
+package terminal
+
+import "unicode"
+
+// graphemeClass is a rune's Grapheme_Cluster_Break property from UAX #29,
+// approximated from the unicode package's tables.  Prepend is rare enough
+// that it's treated as Other.
+type graphemeClass int
+
+const (
+	gcOther graphemeClass = iota
+	gcCR
+	gcLF
+	gcControl
+	gcExtend
+	gcZWJ
+	gcRegionalIndicator
+	gcSpacingMark
+	gcL
+	gcV
+	gcT
+	gcLV
+	gcLVT
+	gcExtendedPictographic
+)
+
+// graphemeClassOf returns the grapheme cluster break class of r
+func graphemeClassOf(r rune) graphemeClass {
+	switch {
+	case r == '\r':
+		return gcCR
+	case r == '\n':
+		return gcLF
+	case r == 0x200d:
+		return gcZWJ
+	case r == 0x200c, r >= 0x1f3fb && r <= 0x1f3ff, r >= 0xe0020 && r <= 0xe007f:
+		// ZWNJ, emoji skin tone modifiers, and emoji tag characters
+		return gcExtend
+	case r >= 0x1f1e6 && r <= 0x1f1ff:
+		return gcRegionalIndicator
+	case unicode.In(r, unicode.Mn, unicode.Me):
+		return gcExtend
+	case unicode.In(r, unicode.Cc, unicode.Cf, unicode.Zl, unicode.Zp):
+		return gcControl
+	case unicode.Is(unicode.Mc, r):
+		return gcSpacingMark
+	case r >= 0x1100 && r <= 0x115f, r >= 0xa960 && r <= 0xa97c:
+		return gcL
+	case r >= 0x1160 && r <= 0x11a7, r >= 0xd7b0 && r <= 0xd7c6:
+		return gcV
+	case r >= 0x11a8 && r <= 0x11ff, r >= 0xd7cb && r <= 0xd7fb:
+		return gcT
+	case r >= 0xac00 && r <= 0xd7a3:
+		if (r-0xac00)%28 == 0 {
+			return gcLV
+		}
+		return gcLVT
+	case isExtendedPictographic(r):
+		return gcExtendedPictographic
+	}
+	return gcOther
+}
+
+// isExtendedPictographic approximates the Extended_Pictographic property,
+// which covers emoji and similar symbols
+func isExtendedPictographic(r rune) bool {
+	switch {
+	case r == 0xa9, r == 0xae, r == 0x203c, r == 0x2049, r == 0x2122, r == 0x2139:
+		return true
+	case r >= 0x2194 && r <= 0x21aa, r >= 0x231a && r <= 0x23ff:
+		return true
+	case r >= 0x25aa && r <= 0x25fe, r >= 0x2600 && r <= 0x27bf:
+		return true
+	case r >= 0x2934 && r <= 0x2935, r >= 0x2b05 && r <= 0x2b55:
+		return true
+	case r == 0x3030, r == 0x303d, r == 0x3297, r == 0x3299:
+		return true
+	case r >= 0x1f000 && r <= 0x1faff, r >= 0x1fc00 && r <= 0x1fffd:
+		return true
+	}
+	return false
+}
+
+// isGraphemeBoundary returns true if text can be split between the runes at
+// i-1 and i without breaking up a grapheme cluster.  The start and end of text
+// are always boundaries.
+func isGraphemeBoundary(text []rune, i int) bool {
+	if i <= 0 || i >= len(text) {
+		return true
+	}
+
+	var prev, next = graphemeClassOf(text[i-1]), graphemeClassOf(text[i])
+	switch {
+	case prev == gcCR && next == gcLF:
+		return false
+	case prev == gcCR, prev == gcLF, prev == gcControl:
+		return true
+	case next == gcCR, next == gcLF, next == gcControl:
+		return true
+	case prev == gcL && (next == gcL || next == gcV || next == gcLV || next == gcLVT):
+		return false
+	case (prev == gcLV || prev == gcV) && (next == gcV || next == gcT):
+		return false
+	case (prev == gcLVT || prev == gcT) && next == gcT:
+		return false
+	case next == gcExtend, next == gcZWJ, next == gcSpacingMark:
+		return false
+	case prev == gcZWJ && next == gcExtendedPictographic:
+		// Emoji joined by ZWJ, with any modifiers on the first emoji
+		var j = i - 2
+		for j >= 0 && graphemeClassOf(text[j]) == gcExtend {
+			j--
+		}
+		return j < 0 || graphemeClassOf(text[j]) != gcExtendedPictographic
+	case prev == gcRegionalIndicator && next == gcRegionalIndicator:
+		// Flags are pairs of regional indicators, so we can only break after an
+		// even number of them
+		var n = 0
+		for j := i - 1; j >= 0 && graphemeClassOf(text[j]) == gcRegionalIndicator; j-- {
+			n++
+		}
+		return n%2 == 0
+	}
+	return true
+}
+
+// nextGraphemeBoundary returns the first grapheme cluster boundary after pos
+func (l *Line) nextGraphemeBoundary(pos int) int {
+	if pos >= len(l.Text) {
+		return len(l.Text)
+	}
+	pos++
+	for !isGraphemeBoundary(l.Text, pos) {
+		pos++
+	}
+	return pos
+}
+
+// prevGraphemeBoundary returns the last grapheme cluster boundary before pos
+func (l *Line) prevGraphemeBoundary(pos int) int {
+	if pos <= 0 {
+		return 0
+	}
+	pos--
+	for !isGraphemeBoundary(l.Text, pos) {
+		pos--
+	}
+	return pos
+}
+
+// clusterStart returns pos if it's a grapheme cluster boundary, or the start
+// of the cluster pos is inside of otherwise
+func (l *Line) clusterStart(pos int) int {
+	if isGraphemeBoundary(l.Text, pos) {
+		return pos
+	}
+	return l.prevGraphemeBoundary(pos)
+}
+
+// clusterEnd returns pos if it's a grapheme cluster boundary, or the end of
+// the cluster pos is inside of otherwise
+func (l *Line) clusterEnd(pos int) int {
+	if isGraphemeBoundary(l.Text, pos) {
+		return pos
+	}
+	return l.nextGraphemeBoundary(pos)
+}
+
+// CountToLeftGrapheme returns the number of runes in the grapheme cluster (a
+// single user-perceived character, such as a flag emoji or a letter with
+// combining accents) before the cursor
+func (l *Line) CountToLeftGrapheme() int {
+	return l.Pos - l.prevGraphemeBoundary(l.Pos)
+}
+
+// CountToRightGrapheme returns the number of runes in the grapheme cluster
+// under the cursor
+func (l *Line) CountToRightGrapheme() int {
+	return l.nextGraphemeBoundary(l.Pos) - l.Pos
+}
@@ -0,0 +1,83 @@
+package terminal
+
+import "testing"
+
+var graphemeTests = []struct {
+	name     string
+	text     string
+	clusters []int
+}{
+	{"ascii", "abc", []int{1, 1, 1}},
+	{"combining accent", "e\u0301x", []int{2, 1}},
+	{"flags", "\U0001F1FA\U0001F1F8\U0001F1EF\U0001F1F5", []int{2, 2}},
+	{"odd regional indicators", "\U0001F1FA\U0001F1F8\U0001F1EF", []int{2, 1}},
+	{"zwj family", "\U0001F468‍\U0001F469‍\U0001F467!", []int{5, 1}},
+	{"skin tone", "\U0001F44D\U0001F3FD", []int{2}},
+	{"variation selector", "❤️", []int{2}},
+	{"crlf", "a\r\nb", []int{1, 2, 1}},
+	{"hangul jamo", "각a", []int{3, 1}},
+	{"spacing mark", "कि", []int{2}},
+}
+
+func TestGraphemeMovement(t *testing.T) {
+	for _, test := range graphemeTests {
+		var l = &Line{Text: []rune(test.text)}
+		var got []int
+		for l.Pos < len(l.Text) {
+			var pos = l.Pos
+			l.MoveRight()
+			got = append(got, l.Pos-pos)
+		}
+		if len(got) != len(test.clusters) {
+			t.Errorf("%s: expected clusters %v, got %v", test.name, test.clusters, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.clusters[i] {
+				t.Errorf("%s: expected clusters %v, got %v", test.name, test.clusters, got)
+				break
+			}
+		}
+
+		// Moving back left has to land on the same boundaries
+		for i := len(test.clusters) - 1; i >= 0; i-- {
+			var pos = l.Pos
+			l.MoveLeft()
+			if pos-l.Pos != test.clusters[i] {
+				t.Errorf("%s: moving left from %d went to %d", test.name, pos, l.Pos)
+			}
+		}
+	}
+}
+
+func TestGraphemeErase(t *testing.T) {
+	// Backspace after a combining accent takes the whole "é"
+	var l = &Line{Text: []rune("ce\u0301"), Pos: 3}
+	l.EraseNPreviousChars(1)
+	if l.String() != "c" || l.Pos != 1 {
+		t.Errorf("Expected %q at 1, got %q at %d", "c", l.String(), l.Pos)
+	}
+
+	// Delete takes the whole flag
+	l.Set([]rune("a\U0001F1FA\U0001F1F8b"), 1)
+	l.DeleteRuneUnderCursor()
+	if l.String() != "ab" || l.Pos != 1 {
+		t.Errorf("Expected %q at 1, got %q at %d", "ab", l.String(), l.Pos)
+	}
+
+	// Transposing swaps clusters
+	l.Set([]rune("e\u0301a"), 2)
+	l.TransposeChars()
+	if l.String() != "ae\u0301" || l.Pos != 3 {
+		t.Errorf("Expected %q at 3, got %q at %d", "ae\u0301", l.String(), l.Pos)
+	}
+}
+
+func TestGraphemeRows(t *testing.T) {
+	// Moving up into a row can't land in the middle of the "é"
+	var l = &Line{Text: []rune("e\u0301x\nab"), Pos: 5}
+	l.MoveUp()
+	if l.Pos != 0 {
+		t.Errorf("Expected up to snap to 0, got %d", l.Pos)
+	}
+}
@@ -0,0 +1,75 @@
+package terminal
+
+import "bytes"
+
+// A Span marks the runes from Start up to (but not including) End as being
+// drawn with Style, a string of SGR parameters such as "1;31" for bold red
+type Span struct {
+	Start, End int
+	Style      string
+}
+
+// A Highlighter colors the input line as the user types, for things like
+// syntax highlighting.  Highlight is given the line's runes and returns the
+// styled spans; runes not covered by a span are drawn unstyled.  If spans
+// overlap, the later span wins.  Highlight must not modify text.
+type Highlighter interface {
+	Highlight(text []rune) []Span
+}
+
+// HighlighterFunc is an adapter to allow the use of an ordinary function as a
+// Highlighter
+type HighlighterFunc func(text []rune) []Span
+
+// Highlight calls f(text)
+func (f HighlighterFunc) Highlight(text []rune) []Span {
+	return f(text)
+}
+
+// highlightStyles appends the style of each rune in text to styles, which is
+// returned.  A nil Highlighter leaves all runes unstyled.
+func highlightStyles(h Highlighter, text []rune, styles []string) []string {
+	var start = len(styles)
+	for range text {
+		styles = append(styles, "")
+	}
+	if h == nil {
+		return styles
+	}
+
+	var n = len(text)
+	for _, span := range h.Highlight(text) {
+		if span.Start < 0 {
+			span.Start = 0
+		}
+		if span.End > n {
+			span.End = n
+		}
+		for i := span.Start; i < span.End; i++ {
+			styles[start+i] = span.Style
+		}
+	}
+	return styles
+}
+
+// writeStyledRunes writes runes to buf, wrapping them in SGR sequences
+// according to the matching entries in styles
+func writeStyledRunes(buf *bytes.Buffer, runes []rune, styles []string) {
+	var current string
+	for i, r := range runes {
+		var style = styles[i]
+		if style != current {
+			if current != "" {
+				buf.WriteString(sgrReset)
+			}
+			if style != "" {
+				buf.WriteString(sgr(style))
+			}
+			current = style
+		}
+		buf.WriteRune(r)
+	}
+	if current != "" {
+		buf.WriteString(sgrReset)
+	}
+}
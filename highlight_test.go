@@ -0,0 +1,68 @@
+package terminal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// keywordHighlighter styles every occurrence of "git" in bold green
+var keywordHighlighter = HighlighterFunc(func(text []rune) []Span {
+	var spans []Span
+	var s = string(text)
+	for i := 0; i+3 <= len(s); i++ {
+		if s[i:i+3] == "git" {
+			spans = append(spans, Span{Start: i, End: i + 3, Style: "1;32"})
+		}
+	}
+	return spans
+})
+
+func TestPromptHighlight(t *testing.T) {
+	var out bytes.Buffer
+	var p = NewPrompt(&MockReader{toSend: []byte("git x\r")}, &out, "> ")
+	p.Highlighter = keywordHighlighter
+	p.ReadLine()
+
+	var s = out.String()
+	if !strings.Contains(s, "\x1b[1;32mgit\x1b[0m") {
+		t.Fatalf("Expected highlighted keyword, got %q", s)
+	}
+
+	// After the highlight is drawn, the cursor has to be moved by visible
+	// cells: three left to get back to the start and three right to return
+	var afterHighlight = s[strings.Index(s, "\x1b[1;32mgit\x1b[0m")+len("\x1b[1;32mgit\x1b[0m"):]
+	if !strings.HasPrefix(afterHighlight, " x") {
+		t.Errorf("Expected cursor to be past the keyword, got %q", afterHighlight)
+	}
+}
+
+func TestPromptHighlightScrolled(t *testing.T) {
+	var out bytes.Buffer
+	var p = NewPrompt(&MockReader{toSend: []byte("abcdefghigit\r")}, &out, "> ")
+	p.Highlighter = keywordHighlighter
+	p.Scroller.InputWidth = 10
+	p.Scroller.MaxLineLength = 20
+	p.Scroller.ScrollBy = 5
+	p.ReadLine()
+
+	var s = out.String()
+	if !strings.Contains(s, "\x1b[1;32mgit\x1b[0m") {
+		t.Errorf("Expected highlighted keyword after scrolling, got %q", s)
+	}
+	if strings.Contains(s, "\x1b[1;32m…") {
+		t.Errorf("Overflow indicator should never be styled, got %q", s)
+	}
+}
+
+func TestAbsPromptHighlight(t *testing.T) {
+	var out bytes.Buffer
+	var p = NewAbsPrompt(&MockReader{toSend: []byte("git")}, &out, "> ")
+	p.Highlighter = keywordHighlighter
+	p.ReadLine()
+	p.WriteChanges()
+
+	if !strings.Contains(out.String(), "\x1b[1;3H\x1b[1;32mgit\x1b[0m") {
+		t.Errorf("Expected highlighted keyword after the prompt, got %q", out.String())
+	}
+}
@@ -0,0 +1,528 @@
+package terminal
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultHistorySize is the number of entries a Reader's history holds unless
+// a different History is assigned
+const DefaultHistorySize = 100
+
+// ErrBadHistoryEscape is returned by History.Load when a line contains an
+// escape sequence or metadata that History.Save could never have written
+var ErrBadHistoryEscape = errors.New("terminal: invalid escape sequence in history data")
+
+// HistoryEntry is a single line of history along with its metadata
+type HistoryEntry struct {
+	// Text is the line the user entered
+	Text string
+
+	// Time is when the entry was added.  It will be zero for entries loaded
+	// from a source which has no timestamps.
+	Time time.Time
+
+	// Tag is an arbitrary label for the entry, such as a session ID, user name,
+	// or hostname.  Entries added via History.Add get the History's Tag.
+	Tag string
+}
+
+// History is a fixed-size ring of previously entered lines.  It is safe for
+// concurrent use, so a single History can be shared by multiple Readers (or
+// Prompts) to give them a common command history.
+type History struct {
+	// IgnoreDups keeps an entry out of the history if it's the same as the
+	// most recent entry, like bash's HISTCONTROL=ignoredups
+	IgnoreDups bool
+
+	// EraseDups removes all older copies of an entry when it's added, like
+	// bash's HISTCONTROL=erasedups
+	EraseDups bool
+
+	// IgnoreSpace keeps entries which start with a space out of the history,
+	// like bash's HISTCONTROL=ignorespace
+	IgnoreSpace bool
+
+	// Filter, if non-nil, is called with each entry before it's added.  If it
+	// returns false, the entry is not stored.  This is useful for keeping
+	// things like passwords out of the history.
+	Filter func(line string) bool
+
+	// Tag is stored on every entry added via Add, which can help identify
+	// which session an entry came from when a history file is shared
+	Tag string
+
+	m sync.RWMutex
+
+	// entries contains max elements
+	entries []HistoryEntry
+	max     int
+	// head contains the index of the element most recently added to the ring
+	head int
+	// size contains the number of elements in the ring
+	size int
+
+	// appendTo, if non-nil, receives each entry as it's added, and appendErr
+	// holds the last error from writing to it until Err is called
+	appendTo  io.Writer
+	appendErr error
+}
+
+// NewHistory returns an empty History which holds up to max entries.  If max
+// is less than one, DefaultHistorySize is used.
+func NewHistory(max int) *History {
+	if max < 1 {
+		max = DefaultHistorySize
+	}
+	return &History{entries: make([]HistoryEntry, max), max: max}
+}
+
+// Max returns the number of entries the history can hold
+func (h *History) Max() int {
+	h.m.RLock()
+	defer h.m.RUnlock()
+	return h.max
+}
+
+// SetMax changes the history's capacity.  If the history is shrinking, the
+// oldest entries are discarded.
+func (h *History) SetMax(max int) {
+	if max < 1 {
+		max = DefaultHistorySize
+	}
+
+	h.m.Lock()
+	defer h.m.Unlock()
+	var list = h.list()
+	if len(list) > max {
+		list = list[len(list)-max:]
+	}
+	h.entries = make([]HistoryEntry, max)
+	h.max = max
+	h.head = 0
+	h.size = 0
+	for _, e := range list {
+		h.add(e)
+	}
+}
+
+// Len returns the number of entries currently stored
+func (h *History) Len() int {
+	h.m.RLock()
+	defer h.m.RUnlock()
+	return h.size
+}
+
+// Clear removes all entries
+func (h *History) Clear() {
+	h.m.Lock()
+	defer h.m.Unlock()
+	for i := range h.entries {
+		h.entries[i] = HistoryEntry{}
+	}
+	h.head = 0
+	h.size = 0
+}
+
+// Add stores a new entry as the most recent, stamped with the current time
+// and the History's Tag.  See AddEntry for details.
+func (h *History) Add(text string) error {
+	return h.AddEntry(HistoryEntry{Text: text, Time: time.Now(), Tag: h.Tag})
+}
+
+// AddEntry stores e as the most recent entry, unless it's rejected by the
+// filtering options (IgnoreDups, IgnoreSpace, and Filter).  If AppendTo has
+// been called, the entry is also written out immediately, and any error from
+// writing it is returned (and kept for Err).  The entry is kept even if it
+// couldn't be written.
+func (h *History) AddEntry(e HistoryEntry) error {
+	if h.IgnoreSpace && strings.HasPrefix(e.Text, " ") {
+		return nil
+	}
+	if h.Filter != nil && !h.Filter(e.Text) {
+		return nil
+	}
+
+	// The filtering and adding happen under one lock so that two goroutines
+	// adding the same text can't both get past IgnoreDups
+	h.m.Lock()
+	var prev, _ = h.nthPrevious(0)
+	if h.IgnoreDups && h.size > 0 && prev.Text == e.Text {
+		h.m.Unlock()
+		return nil
+	}
+	if h.EraseDups {
+		h.erase(e.Text)
+	}
+	h.add(e)
+	var w = h.appendTo
+	h.m.Unlock()
+
+	// The writer is called without the lock held, so it's free to use the
+	// History if it needs to
+	if w != nil && e.Text != "" {
+		var _, err = io.WriteString(w, formatHistoryEntry(e))
+		if err != nil {
+			h.m.Lock()
+			h.appendErr = err
+			h.m.Unlock()
+		}
+		return err
+	}
+	return nil
+}
+
+// add puts e into the ring; the lock must be held
+func (h *History) add(e HistoryEntry) {
+	if h.entries == nil {
+		h.entries = make([]HistoryEntry, DefaultHistorySize)
+		h.max = DefaultHistorySize
+	}
+
+	h.head = (h.head + 1) % h.max
+	h.entries[h.head] = e
+	if h.size < h.max {
+		h.size++
+	}
+}
+
+// erase removes all entries with the given text from the ring; the lock must
+// be held
+func (h *History) erase(text string) {
+	var list = h.list()
+	h.head = 0
+	h.size = 0
+	for _, e := range list {
+		if e.Text != text {
+			h.add(e)
+		}
+	}
+}
+
+// NthPreviousEntry returns the text passed to the nth previous call to Add.
+// If n is zero then the immediately prior value is returned, if one, then the
+// next most recent, and so on. If such an element doesn't exist then ok is
+// false.
+func (h *History) NthPreviousEntry(n int) (value string, ok bool) {
+	var e HistoryEntry
+	e, ok = h.NthPrevious(n)
+	return e.Text, ok
+}
+
+// NthPrevious works like NthPreviousEntry, but returns the full entry
+func (h *History) NthPrevious(n int) (e HistoryEntry, ok bool) {
+	h.m.RLock()
+	defer h.m.RUnlock()
+	return h.nthPrevious(n)
+}
+
+// nthPrevious is NthPrevious without locking
+func (h *History) nthPrevious(n int) (e HistoryEntry, ok bool) {
+	if n < 0 || n >= h.size {
+		return e, false
+	}
+	index := h.head - n
+	if index < 0 {
+		index += h.max
+	}
+	return h.entries[index], true
+}
+
+// Entries returns the text of all stored entries, oldest first
+func (h *History) Entries() []string {
+	h.m.RLock()
+	defer h.m.RUnlock()
+	var list = make([]string, h.size)
+	for i, e := range h.list() {
+		list[i] = e.Text
+	}
+	return list
+}
+
+// FullEntries returns a copy of all stored entries, oldest first
+func (h *History) FullEntries() []HistoryEntry {
+	h.m.RLock()
+	defer h.m.RUnlock()
+	return h.list()
+}
+
+// list returns the entries oldest-first; the lock must be held
+func (h *History) list() []HistoryEntry {
+	var list = make([]HistoryEntry, h.size)
+	for i := range list {
+		list[i], _ = h.nthPrevious(h.size - 1 - i)
+	}
+	return list
+}
+
+// AppendTo sets up w to receive every entry added from now on, one encoded
+// entry per line, which is handy for keeping a history file up to date as
+// lines are submitted rather than saving on exit.  w is usually an *os.File
+// opened with os.O_APPEND.  A nil w turns this off.
+func (h *History) AppendTo(w io.Writer) {
+	h.m.Lock()
+	h.appendTo = w
+	h.m.Unlock()
+}
+
+// Save writes all entries to w, oldest first, in the format Load reads: one
+// entry per line, with backslashes, newlines, and other control characters
+// escaped.  An entry's time and tag, if set, are written on a line before the
+// entry, which starts with "#:" so it looks like a comment to other tools.
+// Empty entries are skipped.
+func (h *History) Save(w io.Writer) error {
+	var bw = bufio.NewWriter(w)
+	for _, e := range h.FullEntries() {
+		if e.Text == "" {
+			continue
+		}
+		bw.WriteString(formatHistoryEntry(e))
+	}
+	return bw.Flush()
+}
+
+// Err returns the error from the most recent entry which couldn't be written
+// to the AppendTo writer, such as a HistoryFile, and clears it.  Reader.ReadLine
+// adds lines to its History without reporting these errors, so Err should be
+// checked after ReadLine to find out that the history isn't being saved.
+func (h *History) Err() error {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	var err = h.appendErr
+	h.appendErr = nil
+	return err
+}
+
+// Load reads entries written by Save (or AppendTo) and adds them to the
+// history as if each had just been entered.  Blank lines and lines starting
+// with "#" (other than metadata lines) are ignored.  If there are more entries
+// than the history can hold, only the most recent are kept.
+func (h *History) Load(r io.Reader) error {
+	var list, err = readHistoryEntries(r)
+	if err != nil {
+		return err
+	}
+
+	h.addAll(list)
+	return nil
+}
+
+// addAll stores each entry in list without sending anything to the AppendTo
+// writer
+func (h *History) addAll(list []HistoryEntry) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	for _, e := range list {
+		h.add(e)
+	}
+}
+
+// missing returns the entries in list which aren't already stored, comparing
+// their text, tag, and time to the second
+func (h *History) missing(list []HistoryEntry) []HistoryEntry {
+	h.m.RLock()
+	defer h.m.RUnlock()
+
+	var have = make(map[HistoryEntry]bool, h.size)
+	for _, e := range h.list() {
+		have[historyEntryKey(e)] = true
+	}
+	var out []HistoryEntry
+	for _, e := range list {
+		if !have[historyEntryKey(e)] {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// historyEntryKey returns e with its time cut to the precision used in
+// history files, so a stored entry matches the same entry read back in
+func historyEntryKey(e HistoryEntry) HistoryEntry {
+	if !e.Time.IsZero() {
+		e.Time = time.Unix(e.Time.Unix(), 0)
+	}
+	return e
+}
+
+// addAllBefore adds list just ahead of the most recent entry with the given
+// text, so entries another session wrote before ours stay ahead of it.  If
+// there's no such entry, list is added as the most recent entries.
+func (h *History) addAllBefore(list []HistoryEntry, text string) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	var all = h.list()
+	var i = len(all) - 1
+	for i >= 0 && all[i].Text != text {
+		i--
+	}
+	if i < 0 {
+		for _, e := range list {
+			h.add(e)
+		}
+		return
+	}
+
+	h.head = 0
+	h.size = 0
+	for _, e := range all[:i] {
+		h.add(e)
+	}
+	for _, e := range list {
+		h.add(e)
+	}
+	for _, e := range all[i:] {
+		h.add(e)
+	}
+}
+
+// historyMetaPrefix starts the line holding an entry's time and tag
+const historyMetaPrefix = "#:"
+
+// formatHistoryEntry returns e in the format Save writes, including the
+// trailing newline
+func formatHistoryEntry(e HistoryEntry) string {
+	var text = encodeHistoryText(e.Text) + "\n"
+	if e.Time.IsZero() && e.Tag == "" {
+		return text
+	}
+
+	var ts string
+	if !e.Time.IsZero() {
+		ts = strconv.FormatInt(e.Time.Unix(), 10)
+	}
+	return historyMetaPrefix + ts + ":" + encodeHistoryText(e.Tag) + "\n" + text
+}
+
+// readHistoryEntries decodes all entries found in r
+func readHistoryEntries(r io.Reader) ([]HistoryEntry, error) {
+	var scanner = bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+
+	var list []HistoryEntry
+	var next HistoryEntry
+	for scanner.Scan() {
+		var line = strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.HasPrefix(line, historyMetaPrefix) {
+			var err error
+			next, err = parseHistoryMeta(line[len(historyMetaPrefix):])
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		var err error
+		next.Text, err = decodeHistoryText(line)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, next)
+		next = HistoryEntry{}
+	}
+	return list, scanner.Err()
+}
+
+// parseHistoryMeta reads the "time:tag" part of a metadata line
+func parseHistoryMeta(s string) (HistoryEntry, error) {
+	var e HistoryEntry
+	var i = strings.IndexByte(s, ':')
+	if i == -1 {
+		return e, ErrBadHistoryEscape
+	}
+
+	if i > 0 {
+		var ts, err = strconv.ParseInt(s[:i], 10, 64)
+		if err != nil {
+			return e, ErrBadHistoryEscape
+		}
+		e.Time = time.Unix(ts, 0)
+	}
+
+	var err error
+	e.Tag, err = decodeHistoryText(s[i+1:])
+	return e, err
+}
+
+const hexDigits = "0123456789abcdef"
+
+// encodeHistoryText escapes s so it fits on a single line.  Backslashes,
+// control characters, and a leading "#" (which would otherwise look like a
+// comment) are escaped.
+func encodeHistoryText(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '#' && i == 0:
+			b.WriteString(`\#`)
+		case r < 0x20 || r == 0x7f:
+			b.WriteString(`\x`)
+			b.WriteByte(hexDigits[r>>4])
+			b.WriteByte(hexDigits[r&0xf])
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// decodeHistoryText reverses encodeHistoryText
+func decodeHistoryText(s string) (string, error) {
+	if strings.IndexByte(s, '\\') == -1 {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		if i == len(s) {
+			return "", ErrBadHistoryEscape
+		}
+		switch s[i] {
+		case '\\', '#':
+			b.WriteByte(s[i])
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'x':
+			if i+2 >= len(s) {
+				return "", ErrBadHistoryEscape
+			}
+			var hi, lo = strings.IndexByte(hexDigits, s[i+1]), strings.IndexByte(hexDigits, s[i+2])
+			if hi == -1 || lo == -1 {
+				return "", ErrBadHistoryEscape
+			}
+			b.WriteByte(byte(hi<<4 | lo))
+			i += 2
+		default:
+			return "", ErrBadHistoryEscape
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,364 @@
+package terminal
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A HistoryFile keeps a History in sync with a file which may be shared by
+// several sessions at once, such as a server running many Readers or a user
+// with multiple local terminals open.  Every entry added to the History is
+// appended to the file in a single write while holding an exclusive advisory
+// lock, so concurrent sessions never interleave or clobber each other's
+// entries.  An append which fails, as when the disk is full, can be found out
+// about with History.Err.
+//
+// Locking is done with flock(2) where it's available.  On other systems the
+// locks are no-ops, and only the O_APPEND semantics protect appends.
+type HistoryFile struct {
+	// Share, when true, makes each append first pull in any entries other
+	// sessions have written since we last looked, similar to zsh's
+	// SHARE_HISTORY option.  Sync can be called to do this on demand, e.g.,
+	// before each new prompt.
+	Share bool
+
+	// TruncateEvery, if above zero, rewrites the file after that many appends
+	// so that it only holds the most recent entries, where "most recent" is
+	// however many entries the History can hold
+	TruncateEvery int
+
+	m       sync.Mutex
+	f       *os.File
+	history *History
+
+	// offset is how far into the file we've already read entries
+	offset int64
+
+	// generation is the header truncate wrote at the start of the file, if
+	// any, which tells us when another session has rewritten the file
+	generation string
+
+	// held holds whole lines other sessions wrote before one of our appends,
+	// which were read so that our entry could be skipped but haven't been
+	// added to the History yet, as Share is off
+	held []byte
+
+	// appends counts our writes since the last truncation
+	appends int
+}
+
+// OpenHistoryFile opens or creates the history file at path, loads its
+// entries into h, and sets h to append all new entries to the file.  Call
+// Close to stop appending and release the file.
+func OpenHistoryFile(path string, h *History) (*HistoryFile, error) {
+	var f, err = os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	var hf = &HistoryFile{f: f, history: h}
+	err = hf.Sync()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	h.AppendTo(hf)
+	return hf, nil
+}
+
+// Write appends p, which must be one or more whole encoded entries, to the
+// file.  This is called by the History for each new entry and shouldn't
+// typically be called directly.
+func (hf *HistoryFile) Write(p []byte) (n int, err error) {
+	hf.m.Lock()
+	defer hf.m.Unlock()
+
+	err = lockFile(hf.f, true)
+	if err != nil {
+		return 0, err
+	}
+	defer unlockFile(hf.f)
+
+	if hf.Share {
+		err = hf.readNew(p)
+	} else {
+		err = hf.holdNew()
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	n, err = hf.f.Write(p)
+	if err != nil {
+		return n, err
+	}
+
+	// Once everything else has been read or held, our own entry shouldn't be
+	// read back in on the next sync
+	var size int64
+	size, err = hf.size()
+	if err != nil {
+		return n, err
+	}
+	if hf.Share || hf.offset+int64(n) == size {
+		hf.offset = size
+	}
+
+	hf.appends++
+	if hf.TruncateEvery > 0 && hf.appends >= hf.TruncateEvery {
+		err = hf.truncate()
+	}
+	return n, err
+}
+
+// Sync reads any entries written to the file by other sessions since the
+// last sync or append, and adds them to the History
+func (hf *HistoryFile) Sync() error {
+	hf.m.Lock()
+	defer hf.m.Unlock()
+
+	var err = lockFile(hf.f, false)
+	if err != nil {
+		return err
+	}
+	defer unlockFile(hf.f)
+
+	return hf.readNew(nil)
+}
+
+// Truncate rewrites the file so it holds only the most recent entries, up to
+// the History's maximum size
+func (hf *HistoryFile) Truncate() error {
+	hf.m.Lock()
+	defer hf.m.Unlock()
+
+	var err = lockFile(hf.f, true)
+	if err != nil {
+		return err
+	}
+	defer unlockFile(hf.f)
+
+	return hf.truncate()
+}
+
+// Close stops the History from appending to the file, then closes the file
+func (hf *HistoryFile) Close() error {
+	hf.history.AppendTo(nil)
+
+	hf.m.Lock()
+	defer hf.m.Unlock()
+	return hf.f.Close()
+}
+
+// size returns the file's current size
+func (hf *HistoryFile) size() (int64, error) {
+	var fi, err = hf.f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// historyGenerationPrefix starts the header line truncate writes.  As it
+// begins with "#", it's skipped like any other comment when entries are read.
+const historyGenerationPrefix = "#generation "
+
+// readGeneration returns the file's generation header, or an empty string if
+// it doesn't start with one
+func (hf *HistoryFile) readGeneration() (string, error) {
+	var data = make([]byte, len(historyGenerationPrefix)+32)
+	var n, err = hf.f.ReadAt(data, 0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	data = data[:n]
+
+	var end = bytes.IndexByte(data, '\n')
+	if end == -1 || !bytes.HasPrefix(data, []byte(historyGenerationPrefix)) {
+		return "", nil
+	}
+	return string(data[:end]), nil
+}
+
+// readNew adds entries found after hf.offset, along with any held ones, to
+// the history.  A partial final line is left for the next read.  If pending is non-nil, it holds our own
+// entry which is about to be written, and which the History already has, so
+// the new entries are put ahead of it.  The file must be locked.
+func (hf *HistoryFile) readNew(pending []byte) error {
+	var size, err = hf.size()
+	if err != nil {
+		return err
+	}
+
+	// If another session has rewritten the file, our offset means nothing, so
+	// we read it all again, skipping the entries we already have
+	var gen string
+	gen, err = hf.readGeneration()
+	if err != nil {
+		return err
+	}
+	if gen != hf.generation {
+		hf.generation = gen
+		hf.held = nil
+		return hf.reread(size, pending)
+	}
+
+	var start = hf.offset
+	var data []byte
+	data, err = hf.readLines(size)
+	if err != nil {
+		return err
+	}
+	data = append(hf.held, data...)
+	if len(data) == 0 {
+		return nil
+	}
+
+	// Bad data partway through the file most likely means we've lost our
+	// place, so we start over rather than failing on every read from now on
+	var list []HistoryEntry
+	list, err = readHistoryEntries(bytes.NewReader(data))
+	if err != nil {
+		if start == 0 && hf.held == nil {
+			return err
+		}
+		hf.held = nil
+		return hf.reread(size, pending)
+	}
+	hf.held = nil
+	hf.add(list, pending)
+	return nil
+}
+
+// holdNew reads the entries other sessions have written since hf.offset
+// into hf.held, without adding them to the History, so that the offset can
+// be moved past our own append.  If the file has been rewritten, it's left
+// for the next readNew to reread.  The file must be locked.
+func (hf *HistoryFile) holdNew() error {
+	var size, err = hf.size()
+	if err != nil {
+		return err
+	}
+	var gen string
+	gen, err = hf.readGeneration()
+	if err != nil || gen != hf.generation {
+		return err
+	}
+
+	var data []byte
+	data, err = hf.readLines(size)
+	hf.held = append(hf.held, data...)
+	return err
+}
+
+// readLines returns the whole lines written after hf.offset, given the
+// file's current size, and moves the offset past them.  A partial final line
+// is left for the next read.
+func (hf *HistoryFile) readLines(size int64) ([]byte, error) {
+	// The file was truncated by something which doesn't write a generation
+	// header; there's no telling which entries are new, so we just skip ahead
+	if size < hf.offset {
+		hf.offset = size
+		return nil, nil
+	}
+	if size == hf.offset {
+		return nil, nil
+	}
+
+	var data = make([]byte, size-hf.offset)
+	var _, err = hf.f.ReadAt(data, hf.offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	var end = bytes.LastIndexByte(data, '\n') + 1
+	hf.offset += int64(end)
+	return data[:end], nil
+}
+
+// add puts entries read from the file into the History, ahead of pending (as
+// with readNew) if it's non-nil
+func (hf *HistoryFile) add(list []HistoryEntry, pending []byte) {
+	var own []HistoryEntry
+	if pending != nil {
+		own, _ = readHistoryEntries(bytes.NewReader(pending))
+	}
+	if len(own) > 0 {
+		hf.history.addAllBefore(list, own[0].Text)
+	} else {
+		hf.history.addAll(list)
+	}
+}
+
+// reread adds every entry in the file which the History doesn't already have,
+// for when we can't trust hf.offset.  size is the file's current size, and
+// pending is as with readNew.
+func (hf *HistoryFile) reread(size int64, pending []byte) error {
+	var data = make([]byte, size)
+	var _, err = hf.f.ReadAt(data, 0)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	var end = bytes.LastIndexByte(data, '\n') + 1
+
+	var list []HistoryEntry
+	list, err = readHistoryEntries(bytes.NewReader(data[:end]))
+	if err != nil {
+		return err
+	}
+	hf.add(hf.history.missing(list), pending)
+	hf.offset = int64(end)
+	return nil
+}
+
+// truncate does the work of Truncate; the file must be locked exclusively
+func (hf *HistoryFile) truncate() error {
+	hf.appends = 0
+
+	var size, err = hf.size()
+	if err != nil {
+		return err
+	}
+	var data = make([]byte, size)
+	_, err = hf.f.ReadAt(data, 0)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	var list []HistoryEntry
+	list, err = readHistoryEntries(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	var max = hf.history.Max()
+	if len(list) <= max {
+		return nil
+	}
+	list = list[len(list)-max:]
+
+	// The file is rewritten in place rather than replaced so that other
+	// sessions' open handles stay valid.  With O_APPEND, writes after
+	// truncating go to the start of the file.
+	err = hf.f.Truncate(0)
+	if err != nil {
+		return err
+	}
+	hf.generation = historyGenerationPrefix + strconv.FormatInt(time.Now().UnixNano(), 10)
+	var w = bufio.NewWriter(hf.f)
+	w.WriteString(hf.generation + "\n")
+	for _, e := range list {
+		w.WriteString(formatHistoryEntry(e))
+	}
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+
+	hf.offset, err = hf.size()
+	return err
+}
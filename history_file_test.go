@@ -0,0 +1,173 @@
+package terminal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func tempHistoryPath(t *testing.T) (string, func()) {
+	var dir, err = ioutil.TempDir("", "terminal-history")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	return filepath.Join(dir, "history"), func() { os.RemoveAll(dir) }
+}
+
+func openTestHistoryFile(t *testing.T, path string, h *History) *HistoryFile {
+	var hf, err = OpenHistoryFile(path, h)
+	if err != nil {
+		t.Fatalf("Unable to open history file: %s", err)
+	}
+	return hf
+}
+
+func TestHistoryFileShare(t *testing.T) {
+	var path, cleanup = tempHistoryPath(t)
+	defer cleanup()
+
+	var h1, h2 = NewHistory(10), NewHistory(10)
+	var hf1 = openTestHistoryFile(t, path, h1)
+	defer hf1.Close()
+	var hf2 = openTestHistoryFile(t, path, h2)
+	defer hf2.Close()
+	hf2.Share = true
+
+	h1.Add("one")
+	h2.Add("two")
+	var list = h2.Entries()
+	if strings.Join(list, ",") != "one,two" {
+		t.Errorf("Expected shared session to have [one two], got %q", list)
+	}
+
+	if err := hf1.Sync(); err != nil {
+		t.Fatalf("Unable to sync: %s", err)
+	}
+	list = h1.Entries()
+	if strings.Join(list, ",") != "one,two" {
+		t.Errorf("Expected synced session to have [one two], got %q", list)
+	}
+
+	var h3 = NewHistory(10)
+	var hf3 = openTestHistoryFile(t, path, h3)
+	hf3.Close()
+	list = h3.Entries()
+	if strings.Join(list, ",") != "one,two" {
+		t.Errorf("Expected new session to load [one two], got %q", list)
+	}
+}
+
+func TestHistoryFileSyncAfterAppend(t *testing.T) {
+	var path, cleanup = tempHistoryPath(t)
+	defer cleanup()
+
+	var h1, h2 = NewHistory(10), NewHistory(10)
+	var hf1 = openTestHistoryFile(t, path, h1)
+	defer hf1.Close()
+	var hf2 = openTestHistoryFile(t, path, h2)
+	defer hf2.Close()
+
+	// Without Share, the other session's entry waits for a sync, and our own
+	// entry written after it mustn't be read back in
+	h2.Add("other")
+	h1.Add("mine")
+	if list := h1.Entries(); strings.Join(list, ",") != "mine" {
+		t.Errorf("Expected unshared session to have [mine], got %q", list)
+	}
+
+	if err := hf1.Sync(); err != nil {
+		t.Fatalf("Unable to sync: %s", err)
+	}
+	if list := h1.Entries(); strings.Join(list, ",") != "mine,other" {
+		t.Errorf("Expected synced session to have [mine other], got %q", list)
+	}
+}
+
+func TestHistoryFileTruncate(t *testing.T) {
+	var path, cleanup = tempHistoryPath(t)
+	defer cleanup()
+
+	var h = NewHistory(3)
+	var hf = openTestHistoryFile(t, path, h)
+	defer hf.Close()
+	hf.TruncateEvery = 5
+	for _, s := range []string{"a", "b", "c", "d"} {
+		h.AddEntry(HistoryEntry{Text: s})
+	}
+	var data, _ = ioutil.ReadFile(path)
+	if string(data) != "a\nb\nc\nd\n" {
+		t.Errorf("Expected no truncation yet, got %q", data)
+	}
+
+	// The rewritten file starts with a generation header, which other
+	// sessions use to notice the rewrite
+	h.AddEntry(HistoryEntry{Text: "e"})
+	data, _ = ioutil.ReadFile(path)
+	if !strings.HasPrefix(string(data), historyGenerationPrefix) || !strings.HasSuffix(string(data), "\nc\nd\ne\n") {
+		t.Errorf("Expected truncation to last three entries, got %q", data)
+	}
+}
+
+func TestHistoryFileRewritten(t *testing.T) {
+	var path, cleanup = tempHistoryPath(t)
+	defer cleanup()
+
+	var h1, h2 = NewHistory(3), NewHistory(10)
+	var hf1 = openTestHistoryFile(t, path, h1)
+	defer hf1.Close()
+	var hf2 = openTestHistoryFile(t, path, h2)
+	defer hf2.Close()
+	hf2.Share = true
+
+	for _, s := range []string{"a", "b", "c", "d"} {
+		if err := h2.AddEntry(HistoryEntry{Text: s}); err != nil {
+			t.Fatalf("Unable to add %q: %s", s, err)
+		}
+	}
+
+	// The first session shrinks the file, then writes enough that it grows
+	// past the second session's old offset, leaving that offset partway
+	// through an entry
+	if err := hf1.Truncate(); err != nil {
+		t.Fatalf("Unable to truncate: %s", err)
+	}
+	h1.AddEntry(HistoryEntry{Text: "a long entry with \\ escapes\nin it"})
+
+	if err := h2.AddEntry(HistoryEntry{Text: "e"}); err != nil {
+		t.Fatalf("Expected the rewrite to be handled, got %s", err)
+	}
+	var list = h2.Entries()
+	if strings.Join(list, ",") != "a,b,c,d,a long entry with \\ escapes\nin it,e" {
+		t.Errorf("Expected the new entry without duplicates, got %q", list)
+	}
+}
+
+func TestHistoryFileConcurrentAppends(t *testing.T) {
+	var path, cleanup = tempHistoryPath(t)
+	defer cleanup()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		var h = NewHistory(10)
+		var hf = openTestHistoryFile(t, path, h)
+		defer hf.Close()
+		hf.Share = true
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				h.AddEntry(HistoryEntry{Text: "entry"})
+			}
+		}()
+	}
+	wg.Wait()
+
+	var data, _ = ioutil.ReadFile(path)
+	if string(data) != strings.Repeat("entry\n", 200) {
+		t.Errorf("Expected 200 intact entries, got %d bytes", len(data))
+	}
+}
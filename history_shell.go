@@ -0,0 +1,212 @@
+package terminal
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LoadBash reads a bash history file (HISTFILE) and adds its entries to the
+// history.  Timestamp comments ("#" followed by epoch seconds, written by bash
+// when HISTTIMEFORMAT is set) are applied to the entry which follows.  When a
+// file has timestamps, all lines between two timestamps are treated as a
+// single multi-line entry, which matches how bash writes multi-line commands
+// with the lithist option; otherwise each line is an entry.
+func (h *History) LoadBash(r io.Reader) error {
+	var scanner = bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+
+	var list []HistoryEntry
+	var timed bool
+	for scanner.Scan() {
+		var line = scanner.Text()
+		if ts, ok := parseBashTimestamp(line); ok {
+			timed = true
+			list = append(list, HistoryEntry{Time: time.Unix(ts, 0)})
+			continue
+		}
+
+		if timed {
+			var current = &list[len(list)-1]
+			if current.Text == "" {
+				current.Text = line
+			} else {
+				current.Text += "\n" + line
+			}
+			continue
+		}
+		if line != "" {
+			list = append(list, HistoryEntry{Text: line})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// Drop timestamps that never got an entry
+	var entries = list[:0]
+	for _, e := range list {
+		if e.Text != "" {
+			entries = append(entries, e)
+		}
+	}
+	h.addAll(entries)
+	return nil
+}
+
+// parseBashTimestamp returns the epoch seconds from a bash timestamp comment
+// line, and whether the line was in fact a timestamp
+func parseBashTimestamp(line string) (int64, bool) {
+	if len(line) < 2 || line[0] != '#' {
+		return 0, false
+	}
+	var ts, err = strconv.ParseInt(line[1:], 10, 64)
+	return ts, err == nil
+}
+
+// SaveBash writes all entries in bash's history format, preceding each entry
+// with a "#epoch" timestamp comment if it has a time.  Multi-line entries are
+// written as-is, so they'll only be read back correctly by bash (or LoadBash)
+// if timestamps are present.
+func (h *History) SaveBash(w io.Writer) error {
+	var bw = bufio.NewWriter(w)
+	for _, e := range h.FullEntries() {
+		if e.Text == "" {
+			continue
+		}
+		if !e.Time.IsZero() {
+			bw.WriteByte('#')
+			bw.WriteString(strconv.FormatInt(e.Time.Unix(), 10))
+			bw.WriteByte('\n')
+		}
+		bw.WriteString(e.Text)
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// LoadZsh reads a zsh history file and adds its entries to the history.  Both
+// plain entries and zsh's extended format (": <epoch>:<duration>;<command>")
+// are understood.  Lines ending in a backslash are joined with the following
+// line, as zsh does for multi-line commands, and zsh's "metafied" bytes are
+// decoded.
+func (h *History) LoadZsh(r io.Reader) error {
+	var scanner = bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+
+	var list []HistoryEntry
+	var text string
+	var continued bool
+	for scanner.Scan() {
+		var line = zshUnmetafy(scanner.Bytes())
+		if continued {
+			text += "\n" + line
+		} else {
+			text = line
+		}
+
+		continued = strings.HasSuffix(text, "\\")
+		if continued {
+			text = text[:len(text)-1]
+			continue
+		}
+
+		var e = parseZshEntry(text)
+		if e.Text != "" {
+			list = append(list, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if continued && text != "" {
+		list = append(list, parseZshEntry(text))
+	}
+
+	h.addAll(list)
+	return nil
+}
+
+// parseZshEntry turns a full (possibly multi-line) zsh history record into a
+// HistoryEntry, reading the extended history prefix if there is one
+func parseZshEntry(s string) HistoryEntry {
+	if !strings.HasPrefix(s, ": ") {
+		return HistoryEntry{Text: s}
+	}
+
+	var semi = strings.IndexByte(s, ';')
+	var colon = strings.IndexByte(s[2:], ':') + 2
+	if semi == -1 || colon < 2 || colon > semi {
+		return HistoryEntry{Text: s}
+	}
+	var ts, err = strconv.ParseInt(s[2:colon], 10, 64)
+	if err != nil {
+		return HistoryEntry{Text: s}
+	}
+	return HistoryEntry{Text: s[semi+1:], Time: time.Unix(ts, 0)}
+}
+
+// SaveZsh writes all entries in zsh's extended history format.  Entries
+// without a time get a timestamp of zero.  The duration is always written as
+// zero, since History doesn't track it.
+func (h *History) SaveZsh(w io.Writer) error {
+	var bw = bufio.NewWriter(w)
+	for _, e := range h.FullEntries() {
+		if e.Text == "" {
+			continue
+		}
+		var ts int64
+		if !e.Time.IsZero() {
+			ts = e.Time.Unix()
+		}
+		bw.WriteString(": ")
+		bw.WriteString(strconv.FormatInt(ts, 10))
+		bw.WriteString(":0;")
+		bw.Write(zshMetafy(strings.Replace(e.Text, "\n", "\\\n", -1)))
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// zshMeta is the byte zsh uses to mark the next byte as "metafied"
+const zshMeta = 0x83
+
+// zshNeedsMeta returns true for the bytes zsh metafies when writing history:
+// NUL and its internal token range
+func zshNeedsMeta(b byte) bool {
+	return b == 0 || (b >= zshMeta && b <= 0xa2)
+}
+
+// zshUnmetafy decodes zsh's metafied bytes in b
+func zshUnmetafy(b []byte) string {
+	if bytes.IndexByte(b, zshMeta) == -1 {
+		return string(b)
+	}
+
+	var out = make([]byte, 0, len(b))
+	for i := 0; i < len(b); i++ {
+		if b[i] == zshMeta && i+1 < len(b) {
+			i++
+			out = append(out, b[i]^32)
+			continue
+		}
+		out = append(out, b[i])
+	}
+	return string(out)
+}
+
+// zshMetafy encodes s the way zsh writes bytes to its history file
+func zshMetafy(s string) []byte {
+	var out = make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if zshNeedsMeta(s[i]) {
+			out = append(out, zshMeta, s[i]^32)
+			continue
+		}
+		out = append(out, s[i])
+	}
+	return out
+}
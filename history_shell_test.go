@@ -0,0 +1,73 @@
+package terminal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBashHistory(t *testing.T) {
+	var h = NewHistory(10)
+	var err = h.LoadBash(strings.NewReader("ls\ncd /tmp\n"))
+	if err != nil {
+		t.Fatalf("Unable to load: %s", err)
+	}
+	if got := strings.Join(h.Entries(), ","); got != "ls,cd /tmp" {
+		t.Errorf("Expected untimed entries [ls cd /tmp], got %s", got)
+	}
+
+	h = NewHistory(10)
+	err = h.LoadBash(strings.NewReader("#1500000000\nls\n#1500000010\nfor x in 1 2; do\necho $x\ndone\n#1500000020\n"))
+	if err != nil {
+		t.Fatalf("Unable to load: %s", err)
+	}
+	var list = h.FullEntries()
+	if len(list) != 2 {
+		t.Fatalf("Expected two timed entries, got %#v", list)
+	}
+	if list[0].Text != "ls" || list[0].Time.Unix() != 1500000000 {
+		t.Errorf("Unexpected first entry %#v", list[0])
+	}
+	if list[1].Text != "for x in 1 2; do\necho $x\ndone" || list[1].Time.Unix() != 1500000010 {
+		t.Errorf("Unexpected second entry %#v", list[1])
+	}
+
+	var buf bytes.Buffer
+	h.SaveBash(&buf)
+	if buf.String() != "#1500000000\nls\n#1500000010\nfor x in 1 2; do\necho $x\ndone\n" {
+		t.Errorf("Unexpected bash output %q", buf.String())
+	}
+}
+
+func TestZshHistory(t *testing.T) {
+	var h = NewHistory(10)
+	var data = ": 1500000000:0;ls -l\n: 1500000005:3;echo one\\\ntwo\nplain\n: 1500000009:0;\x83\xa3\n"
+	var err = h.LoadZsh(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unable to load: %s", err)
+	}
+
+	var list = h.FullEntries()
+	var expected = []HistoryEntry{
+		{Text: "ls -l", Time: time.Unix(1500000000, 0)},
+		{Text: "echo one\ntwo", Time: time.Unix(1500000005, 0)},
+		{Text: "plain"},
+		{Text: "\x83", Time: time.Unix(1500000009, 0)},
+	}
+	if len(list) != len(expected) {
+		t.Fatalf("Expected %d entries, got %#v", len(expected), list)
+	}
+	for i := range expected {
+		if list[i].Text != expected[i].Text || !list[i].Time.Equal(expected[i].Time) {
+			t.Errorf("Entry %d: expected %#v, got %#v", i, expected[i], list[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	h.SaveZsh(&buf)
+	var expectedOut = ": 1500000000:0;ls -l\n: 1500000005:0;echo one\\\ntwo\n: 0:0;plain\n: 1500000009:0;\x83\xa3\n"
+	if buf.String() != expectedOut {
+		t.Errorf("Unexpected zsh output %q", buf.String())
+	}
+}
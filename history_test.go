@@ -0,0 +1,213 @@
+package terminal
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHistorySize(t *testing.T) {
+	var h = NewHistory(3)
+	for _, s := range []string{"a", "b", "c", "d"} {
+		h.Add(s)
+	}
+	if h.Len() != 3 {
+		t.Fatalf("Expected 3 entries, got %d", h.Len())
+	}
+	var e, ok = h.NthPreviousEntry(0)
+	if !ok || e != "d" {
+		t.Errorf("Expected most recent entry to be 'd', got %q", e)
+	}
+	e, ok = h.NthPreviousEntry(2)
+	if !ok || e != "b" {
+		t.Errorf("Expected oldest entry to be 'b', got %q", e)
+	}
+	_, ok = h.NthPreviousEntry(3)
+	if ok {
+		t.Errorf("Expected no fourth entry")
+	}
+
+	h.SetMax(2)
+	var list = h.Entries()
+	if len(list) != 2 || list[0] != "c" || list[1] != "d" {
+		t.Errorf("Expected shrunk history to be [c d], got %q", list)
+	}
+}
+
+func TestHistorySaveLoad(t *testing.T) {
+	var entries = []string{
+		"plain",
+		"multi\nline\r\ntext",
+		`back\slash \n`,
+		"#not a comment",
+		"tab\tand\x1bescape\x7f",
+		"Ξεσκεπάζω",
+	}
+
+	var h = NewHistory(10)
+	for _, e := range entries {
+		h.AddEntry(HistoryEntry{Text: e})
+	}
+	var buf bytes.Buffer
+	if err := h.Save(&buf); err != nil {
+		t.Fatalf("Unable to save: %s", err)
+	}
+	if bytes.Count(buf.Bytes(), []byte("\n")) != len(entries) {
+		t.Fatalf("Expected one line per entry, got %q", buf.String())
+	}
+
+	var h2 = NewHistory(10)
+	if err := h2.Load(bytes.NewBufferString("# comment\n\n" + buf.String())); err != nil {
+		t.Fatalf("Unable to load: %s", err)
+	}
+	var list = h2.Entries()
+	if len(list) != len(entries) {
+		t.Fatalf("Expected %d entries, got %d", len(entries), len(list))
+	}
+	for i := range entries {
+		if list[i] != entries[i] {
+			t.Errorf("Entry %d: expected %q, got %q", i, entries[i], list[i])
+		}
+	}
+
+	if err := h2.Load(bytes.NewBufferString("bad \\q\n")); err != ErrBadHistoryEscape {
+		t.Errorf("Expected ErrBadHistoryEscape, got %v", err)
+	}
+}
+
+func TestHistoryAppendTo(t *testing.T) {
+	var buf bytes.Buffer
+	var h = NewHistory(10)
+	h.AppendTo(&buf)
+	h.AddEntry(HistoryEntry{Text: "one"})
+	h.AddEntry(HistoryEntry{Text: "two\nlines"})
+	if buf.String() != "one\ntwo\\nlines\n" {
+		t.Errorf("Unexpected appended data %q", buf.String())
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestHistoryAppendError(t *testing.T) {
+	var r = NewReader(&MockReader{toSend: []byte("one\r")})
+	r.History.AppendTo(failingWriter{})
+
+	// The line is still read and kept, but the error is there for Err
+	var line, err = r.ReadLine()
+	if line != "one" || err != nil {
+		t.Errorf("Expected %q, got %q (err %v)", "one", line, err)
+	}
+	if r.History.Len() != 1 {
+		t.Errorf("Expected the entry to be kept")
+	}
+	if err = r.History.Err(); err == nil || err.Error() != "disk full" {
+		t.Errorf("Expected the append error, got %v", err)
+	}
+	if err = r.History.Err(); err != nil {
+		t.Errorf("Expected the error to be cleared, got %v", err)
+	}
+}
+
+func TestHistoryConcurrentDups(t *testing.T) {
+	var buf syncBuffer
+	var h = NewHistory(10)
+	h.IgnoreDups = true
+	h.AppendTo(&buf)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			h.AddEntry(HistoryEntry{Text: "same"})
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+
+	if h.Len() != 1 || buf.String() != "same\n" {
+		t.Errorf("Expected one entry to be stored and written, got %d and %q", h.Len(), buf.String())
+	}
+}
+
+func TestSharedHistory(t *testing.T) {
+	var h = NewHistory(10)
+	var r1 = NewReader(&MockReader{toSend: []byte("first\r")})
+	var r2 = NewReader(&MockReader{toSend: []byte("\x1b[A\r")})
+	r1.History = h
+	r2.History = h
+
+	r1.ReadLine()
+	var line, _ = r2.ReadLine()
+	if line != "first" {
+		t.Errorf("Expected shared history to return 'first', got %q", line)
+	}
+}
+
+func TestHistoryFilters(t *testing.T) {
+	var add = func(h *History, entries ...string) string {
+		for _, e := range entries {
+			h.Add(e)
+		}
+		return strings.Join(h.Entries(), ",")
+	}
+
+	var h = NewHistory(10)
+	h.IgnoreDups = true
+	if got := add(h, "a", "a", "b", "a"); got != "a,b,a" {
+		t.Errorf("IgnoreDups: expected a,b,a, got %s", got)
+	}
+
+	h = NewHistory(10)
+	h.EraseDups = true
+	if got := add(h, "a", "b", "a", "c", "b"); got != "a,c,b" {
+		t.Errorf("EraseDups: expected a,c,b, got %s", got)
+	}
+
+	h = NewHistory(10)
+	h.IgnoreSpace = true
+	if got := add(h, "a", " secret", "b"); got != "a,b" {
+		t.Errorf("IgnoreSpace: expected a,b, got %s", got)
+	}
+
+	h = NewHistory(10)
+	h.Filter = func(line string) bool { return !strings.HasPrefix(line, "login ") }
+	if got := add(h, "a", "login token", "b"); got != "a,b" {
+		t.Errorf("Filter: expected a,b, got %s", got)
+	}
+}
+
+func TestHistoryMetadata(t *testing.T) {
+	var h = NewHistory(10)
+	h.Tag = "session:1"
+	h.Add("tagged")
+	h.AddEntry(HistoryEntry{Text: "old", Time: time.Unix(1500000000, 0)})
+	h.AddEntry(HistoryEntry{Text: "bare"})
+
+	var buf bytes.Buffer
+	h.Save(&buf)
+	var h2 = NewHistory(10)
+	if err := h2.Load(&buf); err != nil {
+		t.Fatalf("Unable to load: %s", err)
+	}
+
+	var list = h2.FullEntries()
+	if len(list) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(list))
+	}
+	if list[0].Tag != "session:1" || time.Since(list[0].Time) > time.Minute {
+		t.Errorf("Expected tag and current time on first entry, got %#v", list[0])
+	}
+	if list[1].Tag != "" || list[1].Time.Unix() != 1500000000 {
+		t.Errorf("Expected timestamp only on second entry, got %#v", list[1])
+	}
+	if list[2].Tag != "" || !list[2].Time.IsZero() {
+		t.Errorf("Expected no metadata on third entry, got %#v", list[2])
+	}
+}
@@ -0,0 +1,56 @@
+package terminal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestInterrupt(t *testing.T) {
+	var r = NewReader(&MockReader{toSend: []byte("abc\x03def\r")})
+	var line, err = r.ReadLine()
+	if line != "" || err != ErrInterrupt {
+		t.Errorf("Expected an empty line and ErrInterrupt, got %q and %v", line, err)
+	}
+
+	// The next line starts fresh, and the interrupted one isn't in history
+	line, err = r.ReadLine()
+	if line != "def" || err != nil {
+		t.Errorf("Expected %q, got %q (err %v)", "def", line, err)
+	}
+	if r.History.Len() != 1 {
+		t.Errorf("Expected only one history entry, got %d", r.History.Len())
+	}
+}
+
+func TestInterruptReturnsLine(t *testing.T) {
+	var r = NewReader(&MockReader{toSend: []byte("abc\x03")})
+	r.InterruptReturnsLine = true
+	var line, err = r.ReadLine()
+	if line != "abc" || err != ErrInterrupt {
+		t.Errorf("Expected %q and ErrInterrupt, got %q and %v", "abc", line, err)
+	}
+}
+
+func TestInterruptDisabled(t *testing.T) {
+	var r = NewReader(&MockReader{toSend: []byte("a\x03b\r")})
+	r.InterruptKey = 0
+	var line, err = r.ReadLine()
+	if line != "ab" || err != nil {
+		t.Errorf("Expected %q, got %q (err %v)", "ab", line, err)
+	}
+}
+
+func TestPromptInterrupt(t *testing.T) {
+	var out bytes.Buffer
+	var p = NewPrompt(&MockReader{toSend: []byte("git\x03")}, &out, "> ")
+	p.History.Add("git status")
+	p.Suggester = p.History
+	p.ReadLine()
+
+	// The suggestion is erased, and we end up on a fresh line
+	var expected = "t\x1b[2m status\x1b[0m\x1b[7D       \x1b[7D\r\n"
+	if !strings.HasSuffix(out.String(), expected) {
+		t.Errorf("Expected output to end with %q, got %q", expected, out.String())
+	}
+}
@@ -62,7 +62,18 @@ const (
 	KeyF10
 	KeyF11
 	KeyF12
+	KeyBackTab
+	KeyFocusIn
+	KeyFocusOut
+	KeyMouse
 )
 
+// KeyTab is just a more readable name for CTRL+I, which is what terminals
+// send when the tab key is pressed
+const KeyTab = KeyCtrlI
+
+// KeyCtrlSpace is the NUL byte terminals send for CTRL+Space (or CTRL+@)
+const KeyCtrlSpace = 0
+
 var pasteStart = []byte{KeyEscape, '[', '2', '0', '0', '~'}
 var pasteEnd = []byte{KeyEscape, '[', '2', '0', '1', '~'}
@@ -1,9 +1,45 @@
 package terminal
 
+import "unicode"
+
 // Line manages a very encapsulated version of a terminal line's state
 type Line struct {
 	Text []rune
 	Pos  int
+
+	// IsSeparator, if non-nil, decides which runes separate words for word
+	// motion and deletion.  When nil, PunctuationWords is used.
+	IsSeparator func(r rune) bool
+
+	// Mark is a saved cursor position, set by SetMark and swapped with the
+	// cursor by ExchangeMark
+	Mark int
+
+	// Overwrite is true when typed characters should replace the character
+	// under the cursor rather than being inserted.  Line itself doesn't use
+	// this; it's up to the caller to use OverwriteKey instead of AddKeyToLine.
+	Overwrite bool
+}
+
+// PunctuationWords is a word separator function which splits words on
+// whitespace and punctuation, so "path/to/file" is three words.  This is the
+// default for a Line.
+func PunctuationWords(r rune) bool {
+	return unicode.IsSpace(r) || unicode.IsPunct(r)
+}
+
+// ShellWords is a word separator function which only splits words on
+// whitespace, so "path/to/file" and "--flag=value" are single words
+func ShellWords(r rune) bool {
+	return unicode.IsSpace(r)
+}
+
+// isSeparator returns true if r separates words
+func (l *Line) isSeparator(r rune) bool {
+	if l.IsSeparator == nil {
+		return PunctuationWords(r)
+	}
+	return l.IsSeparator(r)
 }
 
 // Set overwrites Text and Pos with t and p, respectively
@@ -16,6 +52,7 @@ func (l *Line) Set(t []rune, p int) {
 func (l *Line) Clear() {
 	l.Text = l.Text[:0]
 	l.Pos = 0
+	l.Mark = 0
 }
 
 // AddKeyToLine inserts the given key at the current position in the current
@@ -32,6 +69,33 @@ func (l *Line) AddKeyToLine(key rune) {
 	l.Pos++
 }
 
+// OverwriteKey replaces the character under the cursor with key.  At the end
+// of a row, there's nothing to replace, so key is inserted.
+func (l *Line) OverwriteKey(key rune) {
+	if !l.canOverwrite() {
+		l.AddKeyToLine(key)
+		return
+	}
+	l.Replace(l.Pos, l.nextGraphemeBoundary(l.Pos), []rune{key})
+}
+
+// canOverwrite returns true if there's a character under the cursor which
+// OverwriteKey would replace
+func (l *Line) canOverwrite() bool {
+	return l.Pos < len(l.Text) && l.Text[l.Pos] != '\n'
+}
+
+// Replace swaps out the runes from start up to (but not including) end with
+// text, and puts the cursor just after the inserted text
+func (l *Line) Replace(start, end int, text []rune) {
+	var newText = make([]rune, 0, len(l.Text)-(end-start)+len(text))
+	newText = append(newText, l.Text[:start]...)
+	newText = append(newText, text...)
+	newText = append(newText, l.Text[end:]...)
+	l.Text = newText
+	l.Pos = start + len(text)
+}
+
 // String just returns l.Text's runes as a single string
 func (l *Line) String() string {
 	return string(l.Text)
@@ -43,7 +107,8 @@ func (l *Line) Split() (string, string) {
 	return string(l.Text[:l.Pos]), string(l.Text[l.Pos:])
 }
 
-// EraseNPreviousChars deletes n characters from l.Text and updates l.Pos
+// EraseNPreviousChars deletes n runes from l.Text and updates l.Pos.  If that
+// would leave part of a grapheme cluster behind, the whole cluster is erased.
 func (l *Line) EraseNPreviousChars(n int) {
 	if l.Pos == 0 || n == 0 {
 		return
@@ -52,6 +117,7 @@ func (l *Line) EraseNPreviousChars(n int) {
 	if l.Pos < n {
 		n = l.Pos
 	}
+	n = l.Pos - l.clusterStart(l.Pos-n)
 	l.Pos -= n
 
 	copy(l.Text[l.Pos:], l.Text[n+l.Pos:])
@@ -63,7 +129,8 @@ func (l *Line) DeleteLine() {
 	l.Text = l.Text[:l.Pos]
 }
 
-// DeleteRuneUnderCursor erases the character under the current position
+// DeleteRuneUnderCursor erases the character (the whole grapheme cluster)
+// under the current position
 func (l *Line) DeleteRuneUnderCursor() {
 	if l.Pos < len(l.Text) {
 		l.MoveRight()
@@ -85,20 +152,20 @@ func (l *Line) CountToLeftWord() int {
 
 	pos := l.Pos - 1
 	for pos > 0 {
-		if l.Text[pos] != ' ' {
+		if !l.isSeparator(l.Text[pos]) {
 			break
 		}
 		pos--
 	}
 	for pos > 0 {
-		if l.Text[pos] == ' ' {
+		if l.isSeparator(l.Text[pos]) {
 			pos++
 			break
 		}
 		pos--
 	}
 
-	return l.Pos - pos
+	return l.Pos - l.clusterStart(pos)
 }
 
 // MoveToLeftWord moves pos to the first rune of the word to the left
@@ -111,18 +178,58 @@ func (l *Line) MoveToLeftWord() {
 func (l *Line) CountToRightWord() int {
 	pos := l.Pos
 	for pos < len(l.Text) {
-		if l.Text[pos] == ' ' {
+		if l.isSeparator(l.Text[pos]) {
 			break
 		}
 		pos++
 	}
 	for pos < len(l.Text) {
-		if l.Text[pos] != ' ' {
+		if !l.isSeparator(l.Text[pos]) {
 			break
 		}
 		pos++
 	}
-	return pos - l.Pos
+	return l.clusterEnd(pos) - l.Pos
+}
+
+// CountToWordEnd returns the number of characters from the cursor to the end
+// of the current word, or the next word if the cursor isn't in one
+func (l *Line) CountToWordEnd() int {
+	pos := l.Pos
+	for pos < len(l.Text) && l.isSeparator(l.Text[pos]) {
+		pos++
+	}
+	for pos < len(l.Text) && !l.isSeparator(l.Text[pos]) {
+		pos++
+	}
+	return l.clusterEnd(pos) - l.Pos
+}
+
+// EraseNNextChars deletes n runes after the cursor.  If that would leave part
+// of a grapheme cluster behind, the whole cluster is erased.
+func (l *Line) EraseNNextChars(n int) {
+	if l.Pos+n > len(l.Text) {
+		n = len(l.Text) - l.Pos
+	}
+	n = l.clusterEnd(l.Pos+n) - l.Pos
+	if n <= 0 {
+		return
+	}
+
+	copy(l.Text[l.Pos:], l.Text[l.Pos+n:])
+	l.Text = l.Text[:len(l.Text)-n]
+}
+
+// DeletePreviousWord erases from the start of the word to the left up to the
+// cursor
+func (l *Line) DeletePreviousWord() {
+	l.EraseNPreviousChars(l.CountToLeftWord())
+}
+
+// DeleteNextWord erases from the cursor to the end of the current (or next)
+// word
+func (l *Line) DeleteNextWord() {
+	l.EraseNNextChars(l.CountToWordEnd())
 }
 
 // MoveToRightWord moves pos to the first rune of the word to the right
@@ -130,30 +237,102 @@ func (l *Line) MoveToRightWord() {
 	l.Pos += l.CountToRightWord()
 }
 
-// MoveLeft moves pos one rune left
+// MoveLeft moves pos one character left, stepping over a whole grapheme
+// cluster such as a letter with combining accents
 func (l *Line) MoveLeft() {
-	if l.Pos == 0 {
-		return
+	l.Pos = l.prevGraphemeBoundary(l.Pos)
+}
+
+// MoveRight moves pos one character right, stepping over a whole grapheme
+// cluster
+func (l *Line) MoveRight() {
+	l.Pos = l.nextGraphemeBoundary(l.Pos)
+}
+
+// MoveHome moves the cursor to the beginning of the line, or the beginning of
+// the current row if the line has multiple rows
+func (l *Line) MoveHome() {
+	l.Pos = l.rowStart(l.Pos)
+}
+
+// MoveEnd puts the cursor at the end of the line, or the end of the current
+// row if the line has multiple rows
+func (l *Line) MoveEnd() {
+	l.Pos = l.rowEnd(l.Pos)
+}
+
+// rowStart returns the index of the first rune in the row containing pos
+func (l *Line) rowStart(pos int) int {
+	for pos > 0 && l.Text[pos-1] != '\n' {
+		pos--
 	}
+	return pos
+}
 
-	l.Pos--
+// rowEnd returns the index of the newline ending the row containing pos, or
+// the length of the line if that row is the last
+func (l *Line) rowEnd(pos int) int {
+	for pos < len(l.Text) && l.Text[pos] != '\n' {
+		pos++
+	}
+	return pos
 }
 
-// MoveRight moves pos one rune right
-func (l *Line) MoveRight() {
-	if l.Pos == len(l.Text) {
-		return
+// hasNewline returns true if the line has more than one row
+func (l *Line) hasNewline() bool {
+	for _, r := range l.Text {
+		if r == '\n' {
+			return true
+		}
 	}
+	return false
+}
 
-	l.Pos++
+// CursorRow returns the row and column of the cursor, where a line's rows are
+// separated by newline runes.  Both are zero-based, and the column is a count
+// of runes.
+func (l *Line) CursorRow() (row, col int) {
+	var start = l.rowStart(l.Pos)
+	for _, r := range l.Text[:start] {
+		if r == '\n' {
+			row++
+		}
+	}
+	return row, l.Pos - start
 }
 
-// MoveHome moves the cursor to the beginning of the line
-func (l *Line) MoveHome() {
-	l.Pos = 0
+// MoveUp moves the cursor to the same column in the previous row, or that
+// row's end if it's shorter.  It returns false, leaving the cursor alone, if
+// the cursor is already in the first row.
+func (l *Line) MoveUp() bool {
+	var start = l.rowStart(l.Pos)
+	if start == 0 {
+		return false
+	}
+
+	var col = l.Pos - start
+	var prevStart = l.rowStart(start - 1)
+	if prevStart+col > start-1 {
+		col = start - 1 - prevStart
+	}
+	l.Pos = l.clusterStart(prevStart + col)
+	return true
 }
 
-// MoveEnd puts the cursor at the end of the line
-func (l *Line) MoveEnd() {
-	l.Pos = len(l.Text)
+// MoveDown moves the cursor to the same column in the next row, or that row's
+// end if it's shorter.  It returns false, leaving the cursor alone, if the
+// cursor is already in the last row.
+func (l *Line) MoveDown() bool {
+	var end = l.rowEnd(l.Pos)
+	if end == len(l.Text) {
+		return false
+	}
+
+	var col = l.Pos - l.rowStart(l.Pos)
+	var nextEnd = l.rowEnd(end + 1)
+	if end+1+col > nextEnd {
+		col = nextEnd - end - 1
+	}
+	l.Pos = l.clusterStart(end + 1 + col)
+	return true
 }
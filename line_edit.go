@@ -0,0 +1,134 @@
+package terminal
+
+import "unicode"
+
+// This file holds the readline-style text transformations: transposing,
+// changing case, and the mark
+
+// TransposeChars swaps the character before the cursor with the character
+// under it, then moves the cursor right.  At the end of the line, the two
+// characters before the cursor are swapped instead.  Grapheme clusters are
+// swapped as a whole.
+func (l *Line) TransposeChars() {
+	var mid = l.Pos
+	if mid == len(l.Text) {
+		mid = l.prevGraphemeBoundary(mid)
+	}
+	var start, end = l.prevGraphemeBoundary(mid), l.nextGraphemeBoundary(mid)
+	if mid == 0 || mid == start || mid == end {
+		return
+	}
+
+	var left = append([]rune(nil), l.Text[start:mid]...)
+	copy(l.Text[start:], l.Text[mid:end])
+	copy(l.Text[start+end-mid:], left)
+	l.Pos = end
+}
+
+// wordBefore returns the start and end of the last word which ends at or
+// before pos
+func (l *Line) wordBefore(pos int) (start, end int) {
+	for pos > 0 && l.isSeparator(l.Text[pos-1]) {
+		pos--
+	}
+	end = pos
+	for pos > 0 && !l.isSeparator(l.Text[pos-1]) {
+		pos--
+	}
+	return pos, end
+}
+
+// TransposeWords swaps the word at or after the cursor with the word before
+// it, leaving the cursor after both.  At the end of the line, the last two
+// words are swapped.
+func (l *Line) TransposeWords() {
+	// If there's no word after the cursor, this finds the last word instead
+	var rightStart, rightEnd = l.wordBefore(l.Pos + l.CountToWordEnd())
+	var leftStart, leftEnd = l.wordBefore(rightStart)
+	if leftStart == leftEnd || rightStart == rightEnd {
+		return
+	}
+
+	var text = make([]rune, 0, len(l.Text))
+	text = append(text, l.Text[:leftStart]...)
+	text = append(text, l.Text[rightStart:rightEnd]...)
+	text = append(text, l.Text[leftEnd:rightStart]...)
+	text = append(text, l.Text[leftStart:leftEnd]...)
+	text = append(text, l.Text[rightEnd:]...)
+	l.Text = text
+	l.Pos = rightEnd
+}
+
+// mapWord runs fn on each rune from the cursor to the end of the current (or
+// next) word, passing in whether the rune is the word's first, then moves the
+// cursor to the end of the word
+func (l *Line) mapWord(fn func(r rune, first bool) rune) {
+	var end = l.Pos + l.CountToWordEnd()
+	var first = true
+	for i := l.Pos; i < end; i++ {
+		if l.isSeparator(l.Text[i]) {
+			continue
+		}
+		l.Text[i] = fn(l.Text[i], first)
+		first = false
+	}
+	l.Pos = end
+}
+
+// UpcaseWord converts the rest of the current word (or the next word) to
+// upper case and moves the cursor to its end
+func (l *Line) UpcaseWord() {
+	l.mapWord(func(r rune, _ bool) rune { return unicode.ToUpper(r) })
+}
+
+// DowncaseWord converts the rest of the current word (or the next word) to
+// lower case and moves the cursor to its end
+func (l *Line) DowncaseWord() {
+	l.mapWord(func(r rune, _ bool) rune { return unicode.ToLower(r) })
+}
+
+// CapitalizeWord converts the rune under the cursor (or the first rune of the
+// next word) to title case and the rest of the word to lower case, then moves
+// the cursor to the word's end
+func (l *Line) CapitalizeWord() {
+	l.mapWord(func(r rune, first bool) rune {
+		if first {
+			return unicode.ToTitle(r)
+		}
+		return unicode.ToLower(r)
+	})
+}
+
+// SetMark sets the mark to the cursor position
+func (l *Line) SetMark() {
+	l.Mark = l.Pos
+}
+
+// ExchangeMark swaps the cursor position and the mark
+func (l *Line) ExchangeMark() {
+	if l.Mark > len(l.Text) {
+		l.Mark = len(l.Text)
+	}
+	l.Mark = l.clusterStart(l.Mark)
+	l.Pos, l.Mark = l.Mark, l.Pos
+}
+
+// InsertText inserts text at the cursor, moving the cursor after it
+func (l *Line) InsertText(text []rune) {
+	l.Replace(l.Pos, l.Pos, text)
+}
+
+// LastArgument returns the last whitespace-separated word of s, as readline
+// uses for inserting the previous command's last argument
+func LastArgument(s string) string {
+	var runes = []rune(s)
+	var end = len(runes)
+	for end > 0 && unicode.IsSpace(runes[end-1]) {
+		end--
+	}
+	var start = end
+	for start > 0 && !unicode.IsSpace(runes[start-1]) {
+		start--
+	}
+	return string(runes[start:end])
+}
@@ -0,0 +1,76 @@
+package terminal
+
+import "testing"
+
+var lineEditTests = []struct {
+	text     string
+	pos      int
+	edit     func(*Line)
+	expected string
+	pos2     int
+}{
+	{"abc", 1, (*Line).TransposeChars, "bac", 2},
+	{"abc", 3, (*Line).TransposeChars, "acb", 3},
+	{"abc", 0, (*Line).TransposeChars, "abc", 0},
+	{"foo bar", 5, (*Line).TransposeWords, "bar foo", 7},
+	{"foo bar", 3, (*Line).TransposeWords, "bar foo", 7},
+	{"foo bar ", 8, (*Line).TransposeWords, "bar foo ", 7},
+	{"one, two; three", 5, (*Line).TransposeWords, "two, one; three", 8},
+	{"foo", 0, (*Line).TransposeWords, "foo", 0},
+	{"hello world", 0, (*Line).UpcaseWord, "HELLO world", 5},
+	{"HELLO WORLD", 5, (*Line).DowncaseWord, "HELLO world", 11},
+	{"hELLO wORLD", 0, (*Line).CapitalizeWord, "Hello wORLD", 5},
+	{"hello wORLD", 5, (*Line).CapitalizeWord, "hello World", 11},
+	{"élan", 0, (*Line).UpcaseWord, "ÉLAN", 4},
+}
+
+func TestLineEdits(t *testing.T) {
+	for i, test := range lineEditTests {
+		var l = &Line{Text: []rune(test.text), Pos: test.pos}
+		test.edit(l)
+		if l.String() != test.expected || l.Pos != test.pos2 {
+			t.Errorf("Test %d: expected %q at %d, got %q at %d", i, test.expected, test.pos2, l.String(), l.Pos)
+		}
+	}
+}
+
+func TestMark(t *testing.T) {
+	var l = &Line{Text: []rune("abcdef"), Pos: 2}
+	l.SetMark()
+	l.MoveEnd()
+	l.ExchangeMark()
+	if l.Pos != 2 || l.Mark != 6 {
+		t.Errorf("Expected pos 2 and mark 6, got %d and %d", l.Pos, l.Mark)
+	}
+
+	// A mark past the end of the line is clamped
+	l.Text = l.Text[:3]
+	l.ExchangeMark()
+	if l.Pos != 3 {
+		t.Errorf("Expected pos 3, got %d", l.Pos)
+	}
+}
+
+func TestLastArgument(t *testing.T) {
+	var tests = map[string]string{
+		"ls -l /tmp":  "/tmp",
+		"echo a b  ":  "b",
+		"":            "",
+		"single":      "single",
+		"cp x\ty\nz ": "z",
+	}
+	for in, expected := range tests {
+		if got := LastArgument(in); got != expected {
+			t.Errorf("LastArgument(%q): expected %q, got %q", in, expected, got)
+		}
+	}
+}
+
+func TestYankLastArg(t *testing.T) {
+	var r = NewReader(&MockReader{toSend: []byte("vi a.go\rcat b.go\rrm \x1b.\x1b.\r")})
+	r.ReadLine()
+	r.ReadLine()
+	if line, _ := r.ReadLine(); line != "rm a.go" {
+		t.Errorf("Expected a second Alt+. to go further back, got %q", line)
+	}
+}
@@ -0,0 +1,42 @@
+package terminal
+
+import "testing"
+
+var wordTests = []struct {
+	text    string
+	pos     int
+	sep     func(rune) bool
+	left    int
+	right   int
+	wordEnd int
+}{
+	{"path/to/file", 12, nil, 4, 0, 0},
+	{"path/to/file", 12, ShellWords, 12, 0, 0},
+	{"a　b", 0, nil, 0, 2, 1},
+	{"foo, bar", 0, nil, 0, 5, 3},
+	{"foo, bar", 3, nil, 3, 2, 5},
+	{"--flag=value x", 0, ShellWords, 0, 13, 12},
+}
+
+func TestWordMotion(t *testing.T) {
+	for i, test := range wordTests {
+		var l = &Line{Text: []rune(test.text), Pos: test.pos, IsSeparator: test.sep}
+		if n := l.CountToLeftWord(); n != test.left {
+			t.Errorf("Test %d: expected %d to left word, got %d", i, test.left, n)
+		}
+		if n := l.CountToRightWord(); n != test.right {
+			t.Errorf("Test %d: expected %d to right word, got %d", i, test.right, n)
+		}
+		if n := l.CountToWordEnd(); n != test.wordEnd {
+			t.Errorf("Test %d: expected %d to word end, got %d", i, test.wordEnd, n)
+		}
+	}
+}
+
+func TestReaderWordSeparator(t *testing.T) {
+	var r = NewReader(&MockReader{toSend: []byte("cd path/to/file\x17\r")})
+	r.WordSeparator = ShellWords
+	if line, _ := r.ReadLine(); line != "cd " {
+		t.Errorf("Expected ^W to delete the whole path, got %q", line)
+	}
+}
@@ -0,0 +1,81 @@
+package terminal
+
+// startMacro begins recording a new keyboard macro, throwing away the last
+// one
+func (r *Reader) startMacro() {
+	// lock has to be held here
+	r.macro = nil
+	r.recording = true
+}
+
+// stopMacro ends the recording.  The CTRL+X ) which stopped it was recorded
+// before we knew what it was, so it's removed.
+func (r *Reader) stopMacro() {
+	// lock has to be held here
+	if !r.recording {
+		return
+	}
+	r.recording = false
+	if len(r.macro) >= 2 {
+		r.macro = r.macro[:len(r.macro)-2]
+	}
+}
+
+// recordKeypress adds kp to the macro being recorded, if any
+func (r *Reader) recordKeypress(kp Keypress) {
+	// lock has to be held here
+	if !r.recording || r.playing {
+		return
+	}
+	kp.Raw = append([]byte(nil), kp.Raw...)
+	r.macro = append(r.macro, kp)
+}
+
+// queueMacro arranges for the last recorded macro to be played n times once
+// the current key is done.  Macros can't be played while one is being
+// recorded, as the macro would then play itself.
+func (r *Reader) queueMacro(n int) {
+	// lock has to be held here
+	if r.recording || r.playing || n < 1 {
+		return
+	}
+	r.macroPlays = n
+}
+
+// playMacro replays the queued macro's keys through handleKeypress, stopping
+// if one of them submits the line
+func (r *Reader) playMacro() (line string, ok bool) {
+	r.m.Lock()
+	var n, keys = r.macroPlays, r.macro
+	if n == 0 {
+		r.m.Unlock()
+		return
+	}
+	r.macroPlays = 0
+	r.playing = true
+	r.m.Unlock()
+
+	defer func() {
+		r.m.Lock()
+		r.playing = false
+		r.m.Unlock()
+	}()
+
+	for i := 0; i < n && !ok; i++ {
+		for _, kp := range keys {
+			line, ok = r.handleKeypress(kp)
+			if ok {
+				break
+			}
+		}
+	}
+	return
+}
+
+// RecordingMacro returns true while the user is recording a keyboard macro
+// with CTRL+X (
+func (r *Reader) RecordingMacro() bool {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	return r.recording
+}
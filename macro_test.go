@@ -0,0 +1,19 @@
+package terminal
+
+import "testing"
+
+func TestRecordingMacro(t *testing.T) {
+	var r = NewReader(&MockReader{toSend: []byte("\x18(abc\r\x18)\r")})
+	if line, _ := r.ReadLine(); line != "abc" || !r.RecordingMacro() {
+		t.Errorf("Expected to still be recording after %q", line)
+	}
+	r.ReadLine()
+	if r.RecordingMacro() {
+		t.Errorf("Expected recording to have stopped")
+	}
+
+	// The recording spans both lines, without the keys which stopped it
+	if len(r.macro) != 4 || r.macro[3].Key != KeyEnter {
+		t.Errorf("Unexpected macro: %v", r.macro)
+	}
+}
@@ -0,0 +1,53 @@
+package terminal
+
+// A Mask hides the user's input when a Prompt or AbsPrompt draws it, such as
+// when reading a password.  The zero value draws nothing at all.
+type Mask struct {
+	// Rune, if nonzero, is drawn in place of each rune of input
+	Rune rune
+
+	// Placeholder, when Rune is zero, is drawn in place of any non-empty input
+	// regardless of its length, so the length isn't revealed either
+	Placeholder string
+
+	// text is reused to build the masked output
+	text []rune
+}
+
+// NewMask returns a Mask which draws r in place of each rune of input
+func NewMask(r rune) *Mask {
+	return &Mask{Rune: r}
+}
+
+// apply returns a Line holding what should be drawn for l.  The returned Line
+// is only valid until the next call to apply.
+func (m *Mask) apply(l *Line) *Line {
+	m.text = m.text[:0]
+	var pos int
+	switch {
+	case m.Rune != 0:
+		for range l.Text {
+			m.text = append(m.text, m.Rune)
+		}
+		pos = l.Pos
+	case m.Placeholder != "" && len(l.Text) > 0:
+		m.text = append(m.text, []rune(m.Placeholder)...)
+		pos = len(m.text)
+	}
+	return &Line{Text: m.text, Pos: pos}
+}
+
+// masking returns true if m should be applied: it's non-nil and the user
+// hasn't revealed the input
+func (r *Reader) masking(m *Mask) bool {
+	// lock has to be held here
+	return m != nil && !r.revealed
+}
+
+// Revealed returns true if the user has pressed the RevealKey to show masked
+// input as typed
+func (r *Reader) Revealed() bool {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	return r.revealed
+}
@@ -0,0 +1,140 @@
+package terminal
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+var maskTests = []struct {
+	mask     *Mask
+	in       string
+	expected string
+}{
+	{NewMask('*'), "secret\r", "> ******"},
+	{&Mask{Placeholder: "[hidden]"}, "secret\r", "> [hidden]"},
+	{&Mask{}, "secret\r", "> "},
+}
+
+func TestPromptMask(t *testing.T) {
+	for i, test := range maskTests {
+		var out bytes.Buffer
+		var p = NewPrompt(&MockReader{toSend: []byte(test.in)}, &out, "> ")
+		p.Mask = test.mask
+		var line, _ = p.ReadLine()
+		if line != "secret" {
+			t.Errorf("Test %d: expected %q, got %q", i, "secret", line)
+		}
+
+		// Strip cursor movement so we just see what was drawn
+		var drawn = stripMovement(out.String())
+		if strings.Contains(drawn, "s") || drawn != test.expected+"\r\n" {
+			t.Errorf("Test %d: expected %q to be drawn, got %q", i, test.expected, drawn)
+		}
+	}
+}
+
+// stripMovement removes cursor movement sequences from s
+func stripMovement(s string) string {
+	var out []rune
+	var runes = []rune(s)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '\x1b' && i+1 < len(runes) && runes[i+1] == '[' {
+			var j = i + 2
+			for j < len(runes) && runes[j] >= '0' && runes[j] <= '9' {
+				j++
+			}
+			if j < len(runes) && strings.ContainsRune("ABCD", runes[j]) {
+				i = j
+				continue
+			}
+		}
+		out = append(out, runes[i])
+	}
+	return string(out)
+}
+
+func TestPromptReadPassword(t *testing.T) {
+	var out bytes.Buffer
+	var p = NewPrompt(&MockReader{toSend: []byte("pw\r")}, &out, "")
+	var line, _ = p.ReadPassword()
+	if line != "pw" || strings.Contains(out.String(), "pw") {
+		t.Errorf("Expected a masked password, got %q drawn as %q", line, out.String())
+	}
+	if p.Mask != nil || p.History.Len() != 0 {
+		t.Errorf("ReadPassword should restore the mask and skip history")
+	}
+}
+
+func TestAbsPromptReadPassword(t *testing.T) {
+	var in, w = io.Pipe()
+	var out bytes.Buffer
+	var p = NewAbsPrompt(in, &out, "")
+	var keys = make(chan rune, 10)
+	p.AfterKeypress = func(e *KeyEvent) { keys <- e.Key }
+
+	var done = make(chan string)
+	go func() {
+		var line, _ = p.ReadPassword()
+		done <- line
+	}()
+
+	w.Write([]byte("pw"))
+	<-keys
+	<-keys
+	p.WriteChanges()
+	w.Write([]byte("\r"))
+	<-keys
+	if line := <-done; line != "pw" || strings.Contains(out.String(), "pw") || !strings.Contains(out.String(), "**") {
+		t.Errorf("Expected a masked password, got %q drawn as %q", line, out.String())
+	}
+	if p.Mask != nil {
+		t.Errorf("ReadPassword should restore the mask")
+	}
+}
+
+func TestMaskReveal(t *testing.T) {
+	var out bytes.Buffer
+	var p = NewPrompt(&MockReader{toSend: []byte("ab\x1bOQc\r")}, &out, "")
+	p.Mask = NewMask('*')
+	p.RevealKey = KeyF2
+
+	p.ReadLine()
+	if !strings.HasSuffix(stripMovement(out.String()), "**abc\r\n") {
+		t.Errorf("Expected reveal to redraw the plain text, got %q", out.String())
+	}
+	if p.Revealed() {
+		t.Errorf("Expected reveal to reset after the line was submitted")
+	}
+}
+
+func TestMaskScrolling(t *testing.T) {
+	var out bytes.Buffer
+	var p = NewPrompt(&MockReader{toSend: []byte("abcdefghijkl\r")}, &out, "")
+	p.Mask = NewMask('*')
+	p.Scroller.InputWidth = 10
+	p.Scroller.MaxLineLength = 20
+	p.Scroller.ScrollBy = 5
+
+	p.ReadLine()
+	if !strings.Contains(out.String(), "\x1b[8D…***    ") {
+		t.Errorf("Expected the masked line to scroll, got %q", out.String())
+	}
+}
+
+func TestAbsPromptMask(t *testing.T) {
+	var out bytes.Buffer
+	var p = NewAbsPrompt(&MockReader{toSend: []byte("secret")}, &out, "> ")
+	p.Mask = &Mask{Placeholder: "[hidden]"}
+	p.ReadLine()
+
+	p.WriteChanges()
+	var got = out.String()
+	if strings.Contains(got, "secret") || !strings.Contains(got, "\x1b[1;3H[hidden]") {
+		t.Errorf("Expected the placeholder to be drawn, got %q", got)
+	}
+	if !strings.HasSuffix(got, "\x1b[1;11H") {
+		t.Errorf("Expected the cursor after the placeholder, got %q", got)
+	}
+}
@@ -0,0 +1,141 @@
+package terminal
+
+import (
+	"io"
+	"sync"
+)
+
+// Sequences which turn the terminal's reporting modes on and off
+const (
+	bracketedPasteOn  = "\x1b[?2004h"
+	bracketedPasteOff = "\x1b[?2004l"
+	focusOn           = "\x1b[?1004h"
+	focusOff          = "\x1b[?1004l"
+	mouseOn           = "\x1b[?1000h\x1b[?1006h"
+	mouseOff          = "\x1b[?1006l\x1b[?1000l"
+)
+
+// TerminalModes is a set of terminal modes which a Prompt or AbsPrompt turns
+// on when it starts reading a line and off again when ReadLine returns, even
+// if that's due to an error.  Modes can be shared between prompts, and are
+// safe to turn off from another goroutine, such as a signal handler.
+type TerminalModes struct {
+	// BracketedPaste makes the terminal wrap pasted text in KeyPasteStart and
+	// KeyPasteEnd, so the Reader can tell it wasn't typed
+	BracketedPaste bool
+
+	// FocusReporting makes the terminal send KeyFocusIn and KeyFocusOut when
+	// its window gains or loses focus
+	FocusReporting bool
+
+	// MouseReporting makes the terminal send a KeyMouse for each button press
+	// and release.  The Keypress's Raw bytes hold the SGR-style report, such as
+	// "\x1b[<0;12;3M".
+	MouseReporting bool
+
+	m   sync.Mutex
+	out io.Writer
+	on  bool
+}
+
+// NewTerminalModes returns TerminalModes with just bracketed paste turned on
+func NewTerminalModes() *TerminalModes {
+	return &TerminalModes{BracketedPaste: true}
+}
+
+// Enable writes the sequences which turn the modes on to w, and remembers w
+// so that Disable can turn them off again
+func (tm *TerminalModes) Enable(w io.Writer) error {
+	tm.m.Lock()
+	defer tm.m.Unlock()
+
+	var s string
+	if tm.BracketedPaste {
+		s += bracketedPasteOn
+	}
+	if tm.FocusReporting {
+		s += focusOn
+	}
+	if tm.MouseReporting {
+		s += mouseOn
+	}
+
+	tm.out = w
+	tm.on = true
+	if s == "" {
+		return nil
+	}
+	var _, err = io.WriteString(w, s)
+	return err
+}
+
+// Disable turns off whatever modes were turned on by Enable.  It's safe to
+// call more than once, or on a nil TerminalModes.
+func (tm *TerminalModes) Disable() error {
+	if tm == nil {
+		return nil
+	}
+
+	tm.m.Lock()
+	defer tm.m.Unlock()
+	if !tm.on {
+		return nil
+	}
+	tm.on = false
+
+	var s string
+	if tm.MouseReporting {
+		s += mouseOff
+	}
+	if tm.FocusReporting {
+		s += focusOff
+	}
+	if tm.BracketedPaste {
+		s += bracketedPasteOff
+	}
+	if s == "" {
+		return nil
+	}
+	var _, err = io.WriteString(tm.out, s)
+	return err
+}
+
+// Enabled returns true if the modes are currently on
+func (tm *TerminalModes) Enabled() bool {
+	if tm == nil {
+		return false
+	}
+
+	tm.m.Lock()
+	defer tm.m.Unlock()
+	return tm.on
+}
+
+// reenable turns the modes back on after Disable, using the same io.Writer
+func (tm *TerminalModes) reenable() error {
+	tm.m.Lock()
+	var w = tm.out
+	tm.m.Unlock()
+	return tm.Enable(w)
+}
+
+// enableModes turns on modes, writing to w, for the duration of a ReadLine
+func (r *Reader) enableModes(modes *TerminalModes, w io.Writer) error {
+	if modes == nil {
+		return nil
+	}
+
+	r.m.Lock()
+	r.modes = modes
+	r.m.Unlock()
+	return modes.Enable(w)
+}
+
+// disableModes turns off whatever modes enableModes turned on
+func (r *Reader) disableModes() error {
+	r.m.Lock()
+	var modes = r.modes
+	r.modes = nil
+	r.m.Unlock()
+	return modes.Disable()
+}
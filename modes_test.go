@@ -0,0 +1,85 @@
+package terminal
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestPromptModes(t *testing.T) {
+	var out bytes.Buffer
+	var p = NewPrompt(&MockReader{toSend: []byte("hi\r")}, &out, "> ")
+	p.Modes = &TerminalModes{BracketedPaste: true, FocusReporting: true}
+
+	if line, _ := p.ReadLine(); line != "hi" {
+		t.Errorf("Expected %q, got %q", "hi", line)
+	}
+	var expected = "\x1b[?2004h\x1b[?1004h> hi\r\n\x1b[?1004l\x1b[?2004l"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+	if p.Modes.Enabled() {
+		t.Errorf("Expected modes to be off after ReadLine")
+	}
+
+	// Modes have to be turned off when reading fails, too, and Close shouldn't
+	// write anything once they're off
+	out.Reset()
+	if _, err := p.ReadLine(); err != io.EOF {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+	p.Close()
+	if !strings.HasSuffix(out.String(), "\x1b[?2004l") || strings.Count(out.String(), "\x1b[?2004l") != 1 {
+		t.Errorf("Expected modes to be turned off exactly once, got %q", out.String())
+	}
+}
+
+func TestAbsPromptModes(t *testing.T) {
+	var out bytes.Buffer
+	var p = NewAbsPrompt(&MockReader{toSend: []byte("a\x1b[<0;12;3Mb\x1b[<0;12;3m\x1b[Ic\x1b[O\r")}, &out, "> ")
+	p.Modes = &TerminalModes{MouseReporting: true}
+
+	var keys []rune
+	p.OnKeypress = func(e *KeyEvent) {
+		if e.Key == KeyMouse || e.Key == KeyFocusIn || e.Key == KeyFocusOut {
+			keys = append(keys, e.Key)
+		}
+	}
+
+	// Reports are passed along as keys, without getting into the line
+	if line, _ := p.ReadLine(); line != "abc" {
+		t.Errorf("Expected %q, got %q", "abc", line)
+	}
+	if len(keys) != 4 || keys[0] != KeyMouse || keys[1] != KeyMouse || keys[2] != KeyFocusIn || keys[3] != KeyFocusOut {
+		t.Errorf("Unexpected report keys: %v", keys)
+	}
+
+	var expected = "\x1b[?1000h\x1b[?1006h\x1b[?1006l\x1b[?1000l"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestAbsPromptModesReadPassword(t *testing.T) {
+	var out bytes.Buffer
+	var p = NewAbsPrompt(&MockReader{toSend: []byte("pw\rok\r")}, &out, "> ")
+	p.Modes = &TerminalModes{BracketedPaste: true}
+
+	p.ReadPassword()
+	p.ReadLineWithDefault("", 0)
+	if strings.Count(out.String(), "\x1b[?2004h") != 2 || strings.Count(out.String(), "\x1b[?2004l") != 2 {
+		t.Errorf("Expected modes to be turned on and off for each line, got %q", out.String())
+	}
+}
+
+func TestParseMouse(t *testing.T) {
+	// A partial report waits for more bytes unless forced
+	if key, n, _ := ParseKey([]byte("\x1b[<35;1"), false); key != utf8.RuneError || n != 0 {
+		t.Errorf("Expected a partial mouse report to be incomplete, got %q (%d bytes)", key, n)
+	}
+	if key, n, _ := ParseKey([]byte("\x1b[<35;100;200Mx"), false); key != KeyMouse || n != 14 {
+		t.Errorf("Expected a 14-byte mouse report, got %q (%d bytes)", key, n)
+	}
+}
@@ -0,0 +1,78 @@
+package terminal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestIncompleteInput(t *testing.T) {
+	var c = &MockReader{toSend: []byte("select *\rfrom t;\r"), bytesPerRead: 1}
+	var r = NewReader(c)
+	r.IncompleteInput = func(text string) bool {
+		return !strings.HasSuffix(text, ";")
+	}
+
+	var line, err = r.ReadLine()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if line != "select *\nfrom t;" {
+		t.Errorf("Expected a two-row line, got %q", line)
+	}
+}
+
+func TestMultiLineHistory(t *testing.T) {
+	var c = &MockReader{toSend: []byte("one\rtwo\x1b\rthree\r\x1b[A\x1b[A\x1b[A\r"), bytesPerRead: 1}
+	var r = NewReader(c)
+	r.ReadLine()
+	r.ReadLine()
+
+	// The first up recalls the two-row entry, the second moves to its first
+	// row, and the third moves on to the previous entry
+	var line, _ = r.ReadLine()
+	if line != "one" {
+		t.Errorf("Expected up to move through rows before history, got %q", line)
+	}
+}
+
+func TestLineRows(t *testing.T) {
+	var l = &Line{}
+	l.Set([]rune("ab\ncdef\ng"), 6)
+	if row, col := l.CursorRow(); row != 1 || col != 3 {
+		t.Errorf("Expected row 1, column 3, got %d, %d", row, col)
+	}
+	if !l.MoveDown() || l.Pos != 9 {
+		t.Errorf("Expected down to clamp to the end of the last row, got %d", l.Pos)
+	}
+	if l.MoveDown() {
+		t.Errorf("Expected down to fail on the last row")
+	}
+	l.Pos = 1
+	if l.MoveUp() {
+		t.Errorf("Expected up to fail on the first row")
+	}
+}
+
+func TestPromptContinuation(t *testing.T) {
+	var out bytes.Buffer
+	var c = &MockReader{toSend: []byte("ab\x1b\rcd\r")}
+	var p = NewPrompt(c, &out, "> ")
+	p.ContinuationPrompt = ".. "
+
+	var line, err = p.ReadLine()
+	if err != nil || line != "ab\ncd" {
+		t.Fatalf("Expected %q, got %q (err %v)", "ab\ncd", line, err)
+	}
+
+	var got = out.String()
+	if !strings.Contains(got, "ab\r\n.. cd") {
+		t.Errorf("Expected rows drawn with continuation prompt, got %q", got)
+	}
+
+	// After the newline, the cursor goes back up to the first row, back to the
+	// start of the input, and everything below is cleared before redrawing
+	if !strings.Contains(got, "\x1b[1A\r\x1b[2C\x1b[Jab\r\n.. cd") {
+		t.Errorf("Expected the redraw to start at the first row, got %q", got)
+	}
+}
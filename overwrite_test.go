@@ -0,0 +1,93 @@
+package terminal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestOverwriteKey(t *testing.T) {
+	var tests = []struct {
+		text     string
+		pos      int
+		key      rune
+		expected string
+	}{
+		{"abc", 0, 'X', "Xbc"},
+		{"abc", 3, 'X', "abcX"},
+		{"ab\ncd", 2, 'X', "abX\ncd"},
+		{"e\u0301x", 0, 'X', "Xx"},
+	}
+
+	for _, test := range tests {
+		var l = &Line{Text: []rune(test.text), Pos: test.pos}
+		l.OverwriteKey(test.key)
+		if l.String() != test.expected || l.Pos != test.pos+1 {
+			t.Errorf("Overwriting %q at %d: expected %q, got %q (pos %d)", test.text, test.pos, test.expected, l.String(), l.Pos)
+		}
+	}
+}
+
+func TestOverwriteMode(t *testing.T) {
+	var r = NewReader(&MockReader{toSend: []byte("abcd\x01\x1b[2~XY\x1b[2~Z\rabc\x01X\r")})
+	r.MaxLineLength = 4
+
+	// Overwriting is allowed at the maximum length, but inserting isn't
+	if line, _ := r.ReadLine(); line != "XYcd" {
+		t.Errorf("Expected %q, got %q", "XYcd", line)
+	}
+
+	// Each line starts in insert mode
+	if r.Overwrite() {
+		t.Errorf("Expected insert mode after submitting a line")
+	}
+	if line, _ := r.ReadLine(); line != "Xabc" {
+		t.Errorf("Expected %q, got %q", "Xabc", line)
+	}
+}
+
+func TestPromptCursorShape(t *testing.T) {
+	var out bytes.Buffer
+	var p = NewPrompt(&MockReader{toSend: []byte("ab\x1b[2~\x1b[2~\r")}, &out, "> ")
+	p.Cursor = NewCursorStyle()
+	p.ReadLine()
+
+	var shapes []string
+	for _, part := range strings.Split(out.String(), "\x1b[")[1:] {
+		if strings.HasPrefix(part[1:], " q") {
+			shapes = append(shapes, part[:1])
+		}
+	}
+	if strings.Join(shapes, ",") != "6,2,6,0" {
+		t.Errorf("Expected bar, block, bar, then default shapes, got %v in %q", shapes, out.String())
+	}
+}
+
+func TestAbsPromptCursorShape(t *testing.T) {
+	var out bytes.Buffer
+	var p = NewAbsPrompt(&MockReader{}, &out, "> ")
+	p.Cursor = &CursorStyle{Insert: CursorBlinkingBar, Overwrite: CursorUnderline, Restore: CursorBlock}
+
+	// Nothing is set when a line isn't being read
+	p.WriteAll()
+	if strings.Contains(out.String(), " q") {
+		t.Errorf("Expected no cursor shape outside ReadLine, got %q", out.String())
+	}
+
+	p.setReading(true)
+	p.SetOverwrite(true)
+	if !p.NeedWrite() {
+		t.Errorf("Expected a write to be needed for the new cursor shape")
+	}
+	out.Reset()
+	p.WriteChanges()
+	if out.String() != "\x1b[4 q" {
+		t.Errorf("Expected an underline cursor, got %q", out.String())
+	}
+
+	out.Reset()
+	p.setReading(false)
+	if out.String() != "\x1b[2 q" {
+		t.Errorf("Expected the cursor to be restored to a block, got %q", out.String())
+	}
+}
@@ -0,0 +1,102 @@
+package terminal
+
+import "strings"
+
+// A PasteEvent holds everything the user pasted in one bracketed paste, and is
+// given to a Reader's OnPaste callback before the text goes into the line
+type PasteEvent struct {
+	// Text is what was pasted, with line endings converted to "\n".  The
+	// callback can change Text to transform the paste, such as stripping
+	// control characters or joining lines.
+	Text string
+
+	// Line is the line the text will be inserted into, at its cursor.  It
+	// should be considered read-only.
+	Line *Line
+
+	// Truncated is true if the paste was longer than the Reader's MaxPasteSize
+	// and the rest was thrown away
+	Truncated bool
+
+	// Reject can be set to true to drop the paste entirely
+	Reject bool
+}
+
+// structuredPaste returns true if pastes should be collected into a
+// PasteEvent rather than processed a key at a time
+func (r *Reader) structuredPaste() bool {
+	return r.OnPaste != nil || r.MaxPasteSize > 0
+}
+
+// startPaste marks the start of a bracketed paste and gets ready to collect
+// it
+func (r *Reader) startPaste() {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.pasteActive = true
+	r.resetPaste()
+}
+
+// resetPaste empties the paste buffer
+func (r *Reader) resetPaste() {
+	// lock has to be held here
+	r.pasteBuf = r.pasteBuf[:0]
+	r.pasteTruncated = false
+}
+
+// collectPaste adds the raw bytes of a pasted key to the paste buffer,
+// dropping them if that would exceed MaxPasteSize
+func (r *Reader) collectPaste(raw []byte) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if r.pasteTruncated {
+		return
+	}
+	if r.MaxPasteSize > 0 && len(r.pasteBuf)+len(raw) > r.MaxPasteSize {
+		r.pasteTruncated = true
+		return
+	}
+	r.pasteBuf = append(r.pasteBuf, raw...)
+}
+
+// endPaste marks the end of a bracketed paste.  If pastes are being
+// collected, it hands the paste to OnPaste and then, unless it's rejected,
+// inserts it into the line.  AfterKeypress is called with kp (the paste end
+// key) so a Prompt draws the change.
+func (r *Reader) endPaste(kp Keypress) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.pasteActive = false
+	if !r.structuredPaste() {
+		return
+	}
+
+	var text = strings.Replace(string(r.pasteBuf), "\r\n", "\n", -1)
+	text = strings.Replace(text, "\r", "\n", -1)
+	var e = &PasteEvent{Text: text, Line: r.line, Truncated: r.pasteTruncated}
+	r.resetPaste()
+	if r.OnPaste != nil {
+		r.OnPaste(e)
+	}
+
+	if !e.Reject {
+		var runes = []rune(e.Text)
+		if room := r.MaxLineLength - len(r.line.Text); len(runes) > room {
+			if room < 0 {
+				room = 0
+			}
+			runes = runes[:room]
+		}
+		r.line.InsertText(runes)
+		r.completion = nil
+		r.clearValidation()
+		r.updateSuggestion()
+	}
+
+	if r.AfterKeypress != nil {
+		r.AfterKeypress(&KeyEvent{Keypress: kp, Line: r.line})
+	}
+}
@@ -0,0 +1,114 @@
+package terminal
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOnPaste(t *testing.T) {
+	var r = NewReader(&MockReader{toSend: []byte("a\x1b[200~b\r\nc\x7f\x1b[201~d\r"), bytesPerRead: 3})
+	var got *PasteEvent
+	r.OnPaste = func(e *PasteEvent) {
+		got = e
+	}
+
+	// The pasted newline doesn't submit the line, and the pasted backspace is
+	// kept rather than erasing anything
+	var line, err = r.ReadLine()
+	if err != nil || line != "ab\nc\x7fd" {
+		t.Errorf("Expected %q, got %q (err %v)", "ab\nc\x7fd", line, err)
+	}
+	if got == nil || got.Text != "b\nc\x7f" || got.Truncated {
+		t.Errorf("Unexpected paste event: %#v", got)
+	}
+}
+
+func TestOnPasteTransform(t *testing.T) {
+	var r = NewReader(&MockReader{toSend: []byte("\x1b[200~one\rtwo\x1b[201~\r\x1b[200~nope\x1b[201~ok\r")})
+	r.OnPaste = func(e *PasteEvent) {
+		if e.Text == "nope" {
+			e.Reject = true
+		}
+		e.Text = strings.Replace(e.Text, "\n", " ", -1)
+	}
+
+	if line, err := r.ReadLine(); line != "one two" || err != nil {
+		t.Errorf("Expected %q, got %q (err %v)", "one two", line, err)
+	}
+	if line, _ := r.ReadLine(); line != "ok" {
+		t.Errorf("Expected the rejected paste to be dropped, got %q", line)
+	}
+}
+
+func TestMaxPasteSize(t *testing.T) {
+	var r = NewReader(&MockReader{toSend: []byte("\x1b[200~" + strings.Repeat("x", 100) + "\x1b[201~\r")})
+	r.MaxPasteSize = 10
+	if line, _ := r.ReadLine(); line != strings.Repeat("x", 10) {
+		t.Errorf("Expected the paste to be cut to 10 bytes, got %q", line)
+	}
+
+	var truncated bool
+	r = NewReader(&MockReader{toSend: []byte("\x1b[200~" + strings.Repeat("x", 100) + "\x1b[201~\r")})
+	r.MaxPasteSize = 10
+	r.OnPaste = func(e *PasteEvent) { truncated = e.Truncated }
+	r.ReadLine()
+	if !truncated {
+		t.Errorf("Expected the paste event to be marked as truncated")
+	}
+}
+
+func TestMaxPasteSizeKeepsPrefix(t *testing.T) {
+	// The "é" doesn't fit, and the "y" after it would, but has to be dropped
+	// as well so the paste isn't left with a hole in it
+	var r = NewReader(&MockReader{toSend: []byte("\x1b[200~xxxx\u00e9y\x1b[201~\r")})
+	r.MaxPasteSize = 5
+	if line, _ := r.ReadLine(); line != "xxxx" {
+		t.Errorf("Expected %q, got %q", "xxxx", line)
+	}
+}
+
+func TestPromptPaste(t *testing.T) {
+	var out bytes.Buffer
+	var p = NewPrompt(&MockReader{toSend: []byte("\x1b[200~abc\x1b[201~\r")}, &out, "> ")
+	p.OnPaste = func(e *PasteEvent) {}
+	p.ReadLine()
+
+	// The whole paste is drawn at once
+	if out.String() != "> abc\r\n" {
+		t.Errorf("Expected %q, got %q", "> abc\r\n", out.String())
+	}
+}
+
+func TestPasteWithSetLine(t *testing.T) {
+	var in, w = io.Pipe()
+	var r = NewReader(in)
+	r.Suggester = r.History
+	var keys = make(chan rune, 10)
+	r.AfterKeypress = func(e *KeyEvent) { keys <- e.Key }
+
+	var done = make(chan string)
+	go func() {
+		var line, _ = r.ReadLine()
+		done <- line
+	}()
+
+	w.Write([]byte("\x1b[200~x"))
+	<-keys
+	r.SetLine("ab", 2)
+
+	// Give ReadLine a moment to handle the end of the paste, so that SetLine,
+	// which looks at the paste state, runs alongside it (this is meant to be
+	// run with -race)
+	w.Write([]byte("\x1b[201~"))
+	time.Sleep(10 * time.Millisecond)
+	r.SetLine("abc", 3)
+
+	w.Write([]byte("\r"))
+	<-keys
+	if line := <-done; line != "abc" {
+		t.Errorf("Expected %q, got %q", "abc", line)
+	}
+}
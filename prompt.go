@@ -1,8 +1,10 @@
 package terminal
 
 import (
+	"bytes"
 	"io"
 	"strconv"
+	"strings"
 )
 
 // A Prompt is a wrapper around a Reader which will write a prompt, wait for
@@ -11,8 +13,9 @@ import (
 // order to avoid unnecessary writes.
 type Prompt struct {
 	*Reader
-	prompt []byte
-	Out    io.Writer
+	prompt      []byte
+	promptWidth int
+	Out         io.Writer
 
 	// lastOutput mirrors whatever was last printed to the console
 	lastOutput []rune
@@ -21,6 +24,12 @@ type Prompt struct {
 	// parts have changed from lastOutput to nextOutput are printed
 	nextOutput []rune
 
+	// lastStyles and nextStyles hold the SGR parameters for each rune in
+	// lastOutput and nextOutput, respectively, with an empty string meaning
+	// the rune is unstyled
+	lastStyles []string
+	nextStyles []string
+
 	// lastCurPos stores the previous physical cursor position on the screen.
 	// This is a screen position relative to the user's input, not the location
 	// within the full string
@@ -37,18 +46,82 @@ type Prompt struct {
 	// Scroller processes the pending output to figure out if scrolling is
 	// necessary and what should be printed if so
 	Scroller *Scroller
+
+	// Highlighter, if non-nil, is used to style the input line as it's drawn
+	Highlighter Highlighter
+
+	// lineStyles holds the style of each rune in the line
+	lineStyles []string
+
+	// SuggestionStyle holds the SGR parameters used to draw the Reader's
+	// autosuggestion after the input.  It defaults to "2" (dim).
+	SuggestionStyle string
+
+	// Menu lays out tab-completion candidates, which are drawn below the input
+	// line while the user is tabbing through them.  It defaults to an 80-column
+	// menu, and can be set to nil to never draw candidates.
+	Menu *CompletionMenu
+
+	// ErrorStyle holds the SGR parameters used to draw a validation error
+	// below the input.  It defaults to "31" (red).
+	ErrorStyle string
+
+	// ChordStyle holds the SGR parameters used to draw the start of a chord,
+	// such as "C-x-", below the input while the rest is being typed.  It
+	// defaults to "2" (dim).
+	ChordStyle string
+
+	// Mask, if non-nil, hides the input as it's drawn.  Prompt.ReadPassword
+	// uses a mask of asterisks if this isn't set.
+	Mask *Mask
+
+	// menuRows holds the rows which are currently on screen below the input:
+	// the start of a chord and a validation error, if any, and the completion
+	// menu
+	menuRows []string
+
+	// ContinuationPrompt is printed at the start of each row after the first
+	// when the input has multiple lines.  It defaults to "... ".
+	ContinuationPrompt string
+
+	// lastRows is how many rows of input were last drawn, and cursorRow is the
+	// row the cursor is on, counting from the first row of input
+	lastRows  int
+	cursorRow int
+
+	// Modes, if non-nil, are turned on while a line is being read, and turned
+	// off when ReadLine returns or Close is called
+	Modes *TerminalModes
+
+	// Cursor, if non-nil, changes the cursor's shape while a line is being
+	// read to show whether the Reader is in overwrite mode
+	Cursor *CursorStyle
+
+	// cursorShape is the shape the cursor was last given
+	cursorShape CursorShape
+
+	// active is true (with the Reader's lock held) while a line is being read,
+	// so changes made from other goroutines are only drawn when there's a
+	// prompt on screen
+	active bool
 }
 
 // NewPrompt returns a prompt which will read lines from r, write its
 // prompt and current line to w, and use p as the prompt string.
 func NewPrompt(r io.Reader, w io.Writer, p string) *Prompt {
 	var prompt = &Prompt{
-		Reader:    NewReader(r),
-		Out:       w,
-		moveBytes: make([]byte, 2, 16),
+		Reader:      NewReader(r),
+		Out:         w,
+		moveBytes:   make([]byte, 2, 16),
+		cursorShape: cursorUnknown,
 	}
 
 	prompt.Scroller = NewScroller()
+	prompt.Menu = NewCompletionMenu()
+	prompt.SuggestionStyle = "2"
+	prompt.ErrorStyle = "31"
+	prompt.ChordStyle = "2"
+	prompt.ContinuationPrompt = "... "
 
 	// Default input width is "unlimited"; line length is set to the same value
 	// to avoid scrolling
@@ -56,6 +129,9 @@ func NewPrompt(r io.Reader, w io.Writer, p string) *Prompt {
 	prompt.Scroller.MaxLineLength = 9999
 
 	prompt.Reader.AfterKeypress = prompt.afterKeyPress
+	prompt.Reader.redraw = prompt.redraw
+	prompt.Reader.lineChanged = prompt.lineChanged
+	prompt.Reader.restoreCursor = prompt.restoreCursor
 	prompt.SetPrompt(p)
 
 	// Set up the constant moveBytes prefix
@@ -67,29 +143,153 @@ func NewPrompt(r io.Reader, w io.Writer, p string) *Prompt {
 
 // ReadLine delegates to the reader's ReadLine function
 func (p *Prompt) ReadLine() (string, error) {
+	return p.readLine(p.Reader.ReadLine)
+}
+
+// ReadLineWithDefault is ReadLine, but with text already filled in for the
+// user to edit, and the cursor at pos
+func (p *Prompt) ReadLineWithDefault(text string, pos int) (string, error) {
+	return p.readLine(func() (string, error) {
+		return p.Reader.ReadLineWithDefault(text, pos)
+	})
+}
+
+// readLine draws the prompt and calls read to get the user's input
+func (p *Prompt) readLine(read func() (string, error)) (string, error) {
 	p.lastOutput = p.lastOutput[:0]
+	p.lastStyles = p.lastStyles[:0]
 	p.lastCurPos = 0
+	p.menuRows = nil
+	p.lastRows = 1
+	p.cursorRow = 0
+	p.cursorShape = cursorUnknown
 	p.Scroller.Reset()
 	p.MaxLineLength = p.Scroller.MaxLineLength
 
+	defer p.disableModes()
+	if err := p.enableModes(p.Modes, p.Out); err != nil {
+		return "", err
+	}
+
 	p.Out.Write(p.prompt)
-	line, err := p.Reader.ReadLine()
+	p.setActive(true)
+	line, err := read()
+	p.setActive(false)
+	if len(p.menuRows) > 0 {
+		p.writeBelow(nil)
+		p.menuRows = nil
+	}
+	p.moveToLastRow()
 	p.Out.Write(CRLF)
 
 	return line, err
 }
 
+// Close turns off the Prompt's Modes and restores the cursor's shape if a
+// line is being read.  It's meant for cleaning up the terminal when exiting in
+// the middle of a ReadLine, such as from a signal handler.
+func (p *Prompt) Close() error {
+	p.m.Lock()
+	p.restoreCursor()
+	p.m.Unlock()
+	return p.disableModes()
+}
+
+// ReadPassword reads a line without saving it to history, drawing it with the
+// Prompt's Mask, or with asterisks if Mask is nil
+func (p *Prompt) ReadPassword() (line string, err error) {
+	var oldNoHistory, oldMask = p.NoHistory, p.Mask
+	p.NoHistory = true
+	if p.Mask == nil {
+		p.Mask = NewMask('*')
+	}
+	line, err = p.ReadLine()
+	p.NoHistory, p.Mask = oldNoHistory, oldMask
+	return
+}
+
 // SetPrompt changes the current prompt
 func (p *Prompt) SetPrompt(s string) {
 	p.prompt = []byte(s)
+	p.promptWidth = VisualLength(s)
+}
+
+// setActive sets whether a line is being read.  When a line becomes active,
+// anything already in it (such as text set before ReadLine was called) is
+// drawn.
+func (p *Prompt) setActive(active bool) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	p.active = active
+	if !active {
+		p.restoreCursor()
+		return
+	}
+	p.updateCursor()
+	if len(p.Reader.line.Text) > 0 {
+		p.lineChanged()
+	}
+}
+
+// updateCursor sets the cursor's shape for the Reader's mode if it's changed
+func (p *Prompt) updateCursor() {
+	// lock has to be held here
+	if p.Cursor == nil {
+		return
+	}
+	var shape = p.Cursor.shape(p.Reader.line.Overwrite)
+	if shape != p.cursorShape {
+		writeShape(p.Out, shape)
+		p.cursorShape = shape
+	}
+}
+
+// restoreCursor puts the cursor back to the Cursor's Restore shape if
+// updateCursor changed it
+func (p *Prompt) restoreCursor() {
+	// lock has to be held here
+	if p.Cursor == nil || p.cursorShape == cursorUnknown {
+		return
+	}
+	writeShape(p.Out, p.Cursor.Restore)
+	p.cursorShape = cursorUnknown
+}
+
+// lineChanged draws the line after it's been changed by something other than
+// a keypress, such as SetLine
+func (p *Prompt) lineChanged() {
+	// lock has to be held here
+	if p.active {
+		p.writeChanges(&KeyEvent{Line: p.Reader.line})
+	}
+}
+
+// redraw writes the prompt and input again from the start of the current
+// line, for when the screen has been changed out from under us
+func (p *Prompt) redraw() {
+	// lock has to be held here
+	if !p.active {
+		return
+	}
+	p.lastOutput = p.lastOutput[:0]
+	p.lastStyles = p.lastStyles[:0]
+	p.lastCurPos = 0
+	p.lastRows = 1
+	p.cursorRow = 0
+	p.menuRows = nil
+	p.cursorShape = cursorUnknown
+
+	p.Out.Write([]byte("\r\x1b[J"))
+	p.Out.Write(p.prompt)
+	p.writeChanges(&KeyEvent{Line: p.Reader.line})
 }
 
 // afterKeyPress calls Prompt's key handler to draw changes, then the user-
 // defined callback if present
 func (p *Prompt) afterKeyPress(e *KeyEvent) {
-	// We never write changes when enter is pressed, because the line has been
-	// cleared by the Reader, and is about to be returned
-	if e.Key != KeyEnter {
+	// We never write changes when a line is submitted, because the line has
+	// been cleared by the Reader, and is about to be returned
+	if !e.submitted {
 		p.writeChanges(e)
 	}
 	if p.AfterKeypress != nil {
@@ -101,33 +301,215 @@ func (p *Prompt) afterKeyPress(e *KeyEvent) {
 // the console and the new line, attempting to draw the smallest amount of data
 // to get things back in sync
 func (p *Prompt) writeChanges(e *KeyEvent) {
-	var out, curPos = p.Scroller.Filter(e.Line)
+	p.updateCursor()
+
+	// Masked input is drawn without highlighting or suggestions, which could
+	// give away what was typed
+	var line, highlighter, suggestion = e.Line, p.Highlighter, p.Reader.suggestion
+	if p.Reader.masking(p.Mask) {
+		line, highlighter, suggestion = p.Mask.apply(line), nil, nil
+	}
+	line, highlighter = caretNotation(line, highlighter)
+
+	if p.lastRows > 1 || line.hasNewline() {
+		p.writeRows(line, highlighter, suggestion)
+		p.writeMenu()
+		return
+	}
+
+	var out, curPos = p.Scroller.Filter(line)
 	p.nextOutput = append(p.nextOutput[:0], out...)
 
+	// Styles are figured out for the full line, then we grab the visible ones.
+	// Overflow indicators are left unstyled.
+	p.lineStyles = highlightStyles(highlighter, line.Text, p.lineStyles[:0])
+	var offset = p.Scroller.offset()
+	p.nextStyles = p.nextStyles[:0]
+	for i, r := range out {
+		var style string
+		if offset+i < len(line.Text) && line.Text[offset+i] == r {
+			style = p.lineStyles[offset+i]
+		}
+		p.nextStyles = append(p.nextStyles, style)
+	}
+
+	// Any autosuggestion is drawn after the line, but only as much as fits
+	var cells = RunesWidth(out)
+	for _, r := range suggestion {
+		if p.Scroller.InputWidth > 0 && cells+RuneWidth(r) > p.Scroller.InputWidth {
+			break
+		}
+		cells += RuneWidth(r)
+		p.nextOutput = append(p.nextOutput, r)
+		p.nextStyles = append(p.nextStyles, p.SuggestionStyle)
+	}
+
 	// Pad output if it's shorter than last output
-	var outputLen = len(p.nextOutput)
-	for outputLen < len(p.lastOutput) {
+	for lastCells := RunesWidth(p.lastOutput); cells < lastCells; cells++ {
 		p.nextOutput = append(p.nextOutput, ' ')
-		outputLen++
+		p.nextStyles = append(p.nextStyles, "")
 	}
 
 	// Compare last output with what we need to print next so we only redraw
 	// starting from where they differ
 	var index = runesDiffer(p.lastOutput, p.nextOutput)
+	var styleIndex = stylesDiffer(p.lastStyles, p.nextStyles)
+	if styleIndex >= 0 && (index < 0 || styleIndex < index) {
+		index = styleIndex
+	}
 	if index >= 0 {
-		p.moveCursor(index)
-		var out = p.nextOutput[index:]
-		p.lastCurPos += len(out)
-		p.Out.Write([]byte(string(out)))
+		p.moveCursor(RunesWidth(p.nextOutput[:index]))
+		p.lastCurPos += RunesWidth(p.nextOutput[index:])
+		p.writeStyled(index)
 		p.lastOutput = append(p.lastOutput[:0], p.nextOutput...)
+		p.lastStyles = append(p.lastStyles[:0], p.nextStyles...)
 	}
 
-	// Make sure that after all the redrawing, the cursor gets back to where it should be
-	p.moveCursor(curPos)
+	// Make sure that after all the redrawing, the cursor gets back to where it
+	// should be.  The cursor can be just past the visible runes, in which case
+	// moveCursor keeps it in the input area.
+	var curCol int
+	if curPos <= len(out) {
+		curCol = RunesWidth(out[:curPos])
+	} else {
+		curCol = RunesWidth(out) + curPos - len(out)
+	}
+	p.moveCursor(curCol)
+
+	p.writeMenu()
+}
+
+// writeRows redraws the whole input when it spans multiple rows (or just
+// stopped doing so), printing ContinuationPrompt before each row after the
+// first.  The Scroller isn't used here, as each row is drawn in full.
+func (p *Prompt) writeRows(line *Line, highlighter Highlighter, suggestion []rune) {
+	var text = line.Text
+	p.lineStyles = highlightStyles(highlighter, text, p.lineStyles[:0])
+
+	// Go back to the start of the input and clear everything after it
+	var buf bytes.Buffer
+	writeMove(&buf, p.cursorRow, 'A')
+	buf.WriteByte('\r')
+	writeMove(&buf, p.promptWidth, 'C')
+	buf.WriteString("\x1b[J")
+
+	var rows = 1
+	var start = 0
+	for i := 0; i <= len(text); i++ {
+		if i < len(text) && text[i] != '\n' {
+			continue
+		}
+		writeStyledRunes(&buf, text[start:i], p.lineStyles[start:i])
+		if i < len(text) {
+			buf.WriteString("\r\n")
+			buf.WriteString(p.ContinuationPrompt)
+			rows++
+		}
+		start = i + 1
+	}
+
+	if len(suggestion) > 0 {
+		var styles = make([]string, len(suggestion))
+		for i := range styles {
+			styles[i] = p.SuggestionStyle
+		}
+		writeStyledRunes(&buf, suggestion, styles)
+	}
+
+	// Put the cursor where it belongs
+	var row, col = line.CursorRow()
+	col = RunesWidth(line.Text[line.Pos-col : line.Pos])
+	writeMove(&buf, rows-1-row, 'A')
+	buf.WriteByte('\r')
+	writeMove(&buf, p.rowStart(row)+col, 'C')
+	p.Out.Write(buf.Bytes())
+
+	p.lastRows = rows
+	p.cursorRow = row
+	p.lastCurPos = col
+
+	// Clearing took out anything drawn below the input, so writeMenu has to
+	// draw it again
+	p.menuRows = nil
+
+	// The next single-row draw has nothing to diff against
+	p.lastOutput = p.lastOutput[:0]
+	p.lastStyles = p.lastStyles[:0]
+}
+
+// rowStart returns the screen column at which the given row of input starts
+func (p *Prompt) rowStart(row int) int {
+	if row == 0 {
+		return p.promptWidth
+	}
+	return VisualLength(p.ContinuationPrompt)
+}
+
+// moveToLastRow moves the cursor down to the last row of input
+func (p *Prompt) moveToLastRow() {
+	var buf bytes.Buffer
+	writeMove(&buf, p.lastRows-1-p.cursorRow, 'B')
+	p.Out.Write(buf.Bytes())
+	p.cursorRow = p.lastRows - 1
+}
+
+// writeMove writes the ANSI sequence to move n cells in the direction given
+// by dir ('A', 'B', 'C', or 'D'), or nothing if n isn't positive
+func writeMove(buf *bytes.Buffer, n int, dir byte) {
+	if n < 1 {
+		return
+	}
+	buf.WriteString("\x1b[")
+	buf.WriteString(strconv.Itoa(n))
+	buf.WriteByte(dir)
+}
+
+// writeStyled writes nextOutput from the given index on, wrapping runes in
+// SGR sequences according to their styles
+func (p *Prompt) writeStyled(index int) {
+	var buf bytes.Buffer
+	writeStyledRunes(&buf, p.nextOutput[index:], p.nextStyles[index:])
+	p.Out.Write(buf.Bytes())
+}
+
+// writeMenu draws any validation error and the tab-completion menu below the
+// input line if they've changed, or erases them once they're gone
+func (p *Prompt) writeMenu() {
+	var rows = p.Reader.completionMenu(p.Menu)
+	if p.Reader.invalid != "" {
+		rows = append([]string{sgr(p.ErrorStyle) + p.Reader.invalid + sgrReset}, rows...)
+	}
+	if pending := p.Reader.pendingChord(); pending != "" {
+		rows = append([]string{sgr(p.ChordStyle) + pending + sgrReset}, rows...)
+	}
+	if strings.Join(rows, "\n") == strings.Join(p.menuRows, "\n") {
+		return
+	}
+	p.writeBelow(rows)
+	p.menuRows = rows
+}
+
+// writeBelow erases everything below the input line, writes rows there, and
+// then puts the cursor back where it was
+func (p *Prompt) writeBelow(rows []string) {
+	var buf bytes.Buffer
+	var down = p.lastRows - 1 - p.cursorRow
+	writeMove(&buf, down, 'B')
+	buf.WriteString("\r\n\x1b[J")
+	buf.WriteString(strings.Join(rows, "\r\n"))
+
+	var up = len(rows)
+	if up == 0 {
+		up = 1
+	}
+	writeMove(&buf, up+down, 'A')
+	buf.WriteByte('\r')
+	writeMove(&buf, p.rowStart(p.cursorRow)+p.lastCurPos, 'C')
+	p.Out.Write(buf.Bytes())
 }
 
-// moveCursor moves the cursor to the given x location (relative to the
-// beginning of the user's input area)
+// moveCursor moves the cursor to the given x location, in terminal cells
+// (relative to the beginning of the user's input area)
 func (p *Prompt) moveCursor(x int) {
 	if x >= p.Scroller.InputWidth {
 		x = p.Scroller.InputWidth - 1
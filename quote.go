@@ -0,0 +1,84 @@
+package terminal
+
+// quoting returns true if the next key should be put into the line as-is
+func (r *Reader) quoting() bool {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	return r.quotePending
+}
+
+// insertQuoted puts kp into the line verbatim: its raw bytes if it came from
+// a key sequence (so a quoted left arrow inserts "\x1b[D"), or its rune
+// otherwise
+func (r *Reader) insertQuoted(kp Keypress) {
+	// lock has to be held here
+	var runes = []rune(string(kp.Raw))
+	if len(runes) == 0 {
+		runes = []rune{kp.Key}
+	}
+	for _, key := range runes {
+		r.addKey(key)
+	}
+}
+
+// isCaretControl returns true if r is a control character which is drawn in
+// caret notation.  Newlines are left alone, since they split multi-line input.
+func isCaretControl(r rune) bool {
+	return (r < 32 && r != '\n') || r == 127
+}
+
+// caretNotation returns the Line and Highlighter to draw for l, where any
+// control characters are shown in caret notation, such as ^A for CTRL+A and
+// ^[ for Escape.  If l has no control characters, it's returned as-is.
+func caretNotation(l *Line, h Highlighter) (*Line, Highlighter) {
+	var n int
+	for _, r := range l.Text {
+		if isCaretControl(r) {
+			n++
+		}
+	}
+	if n == 0 {
+		return l, h
+	}
+
+	// index holds where each rune of l.Text starts in the drawn text, plus
+	// the end of the drawn text, so spans and the cursor can be moved to match
+	var text = make([]rune, 0, len(l.Text)+n)
+	var index = make([]int, 0, len(l.Text)+1)
+	for _, r := range l.Text {
+		index = append(index, len(text))
+		if isCaretControl(r) {
+			text = append(text, '^', r^0x40)
+			continue
+		}
+		text = append(text, r)
+	}
+	index = append(index, len(text))
+
+	var shown = &Line{Text: text, Pos: index[l.Pos]}
+	if h == nil {
+		return shown, nil
+	}
+
+	var original = l.Text
+	return shown, HighlighterFunc(func([]rune) []Span {
+		var spans []Span
+		for _, span := range h.Highlight(original) {
+			span.Start = index[clamp(span.Start, 0, len(original))]
+			span.End = index[clamp(span.End, 0, len(original))]
+			spans = append(spans, span)
+		}
+		return spans
+	})
+}
+
+// clamp returns n limited to the range from min to max
+func clamp(n, min, max int) int {
+	if n < min {
+		return min
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
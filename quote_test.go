@@ -0,0 +1,73 @@
+package terminal
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestQuotedInsert(t *testing.T) {
+	var tests = []struct {
+		input    string
+		expected string
+	}{
+		{"a\x16\tb\r", "a\tb"},
+		{"\x16\x03\r", "\x03"},
+		{"\x16\r\r", "\r"},
+		{"\x16\x1b[D\r", "\x1b[D"},
+		{"\x16\x16x\r", "\x16x"},
+		{"\x16\x1b[200~a\r", "\x1b[200~a"},
+	}
+
+	for _, test := range tests {
+		var r = NewReader(&MockReader{toSend: []byte(test.input)})
+		var line, err = r.ReadLine()
+		if line != test.expected || err != nil {
+			t.Errorf("Input %q: expected %q, got %q (err %v)", test.input, test.expected, line, err)
+		}
+	}
+}
+
+func TestCaretNotation(t *testing.T) {
+	var h = HighlighterFunc(func(text []rune) []Span {
+		return []Span{{Start: 1, End: 3, Style: "1"}}
+	})
+	var l = &Line{Text: []rune("a\x01b\x7f\nc"), Pos: 4}
+	var shown, sh = caretNotation(l, h)
+	if string(shown.Text) != "a^Ab^?\nc" || shown.Pos != 6 {
+		t.Errorf("Expected %q at 6, got %q at %d", "a^Ab^?\nc", string(shown.Text), shown.Pos)
+	}
+
+	var spans = sh.Highlight(shown.Text)
+	if len(spans) != 1 || spans[0].Start != 1 || spans[0].End != 4 {
+		t.Errorf("Expected the span to cover ^Ab, got %#v", spans)
+	}
+
+	// Lines without control characters are drawn as-is
+	l = &Line{Text: []rune("abc")}
+	if shown, _ = caretNotation(l, nil); shown != l {
+		t.Errorf("Expected the same line back")
+	}
+}
+
+func TestPromptCaretNotation(t *testing.T) {
+	var out bytes.Buffer
+	var p = NewPrompt(&MockReader{toSend: []byte("a\x16\x1bb\x1b[D\r")}, &out, "> ")
+	p.ReadLine()
+
+	var expected = "> a^[b\x1b[D\r\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestAbsPromptCaretNotation(t *testing.T) {
+	var out bytes.Buffer
+	var p = NewAbsPrompt(&MockReader{}, &out, "> ")
+	p.SetLine("a\tb", 2)
+	p.WriteAll()
+
+	var expected = "\x1b[1;1H> \x1b[1;3Ha^Ib\x1b[1;6H"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
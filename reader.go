@@ -7,7 +7,9 @@ package terminal
 
 import (
 	"io"
+	"strings"
 	"sync"
+	"time"
 	"unicode/utf8"
 )
 
@@ -22,6 +24,16 @@ type KeyEvent struct {
 	Keypress
 	Line                  *Line
 	IgnoreDefaultHandlers bool
+
+	// Argument is the numeric argument typed before a chord, or 1 if there
+	// wasn't one, in which case HasArgument is false.  These are only set for
+	// the functions given to Reader.Bind.
+	Argument    int
+	HasArgument bool
+
+	// submitted is true when the key resulted in the line being returned to
+	// the caller, which means Line has already been cleared
+	submitted bool
 }
 
 // Reader contains the state for running a VT100 terminal that is capable of
@@ -44,6 +56,12 @@ type Reader struct {
 	keyReader *KeyReader
 	m         sync.RWMutex
 
+	// HistoryPrefixSearch, when true, makes the up and down keys only visit
+	// history entries which start with the text to the left of the cursor, as
+	// fish and zsh can do.  The text used as the search prefix is captured when
+	// the user first leaves the line they were typing.
+	HistoryPrefixSearch bool
+
 	// NoHistory is on when we don't want to preserve history, such as when a
 	// password is being entered
 	NoHistory bool
@@ -52,10 +70,70 @@ type Reader struct {
 	// like allowing up/down/left/right and other control keys)
 	MaxLineLength int
 
+	// Completer, if non-nil, is asked for candidates when the user presses tab.
+	// See Completer and Reader.Completions for details.
+	Completer Completer
+
+	// Suggester, if non-nil, is asked for an autosuggestion after each
+	// keypress.  The Reader's History can be used here to get fish-style
+	// suggestions from previously entered lines.
+	Suggester Suggester
+
+	// IncompleteInput, if non-nil, is called when enter is pressed.  If it
+	// returns true, a newline is inserted rather than the line being returned,
+	// which allows for multi-line input such as SQL statements or JSON.
+	// Regardless of this function, Alt+Enter always inserts a newline.
+	IncompleteInput func(text string) bool
+
+	// Validator, if non-nil, is called when enter is pressed, and can keep a
+	// bad line from being submitted.  See Validator for details.
+	Validator Validator
+
+	// RevealKey, if nonzero, toggles whether a Prompt or AbsPrompt with a Mask
+	// draws the input as typed.  It should be a key the Reader otherwise
+	// ignores, such as KeyF2.  Each line starts out hidden.
+	RevealKey rune
+
+	// WordSeparator, if non-nil, decides which runes separate words for word
+	// motion and deletion, such as ShellWords.  It defaults to
+	// PunctuationWords.  See Line.IsSeparator.
+	WordSeparator func(r rune) bool
+
 	// CloseKey is the key which, when used on a terminal line by itself, closes
 	// the terminal.  Defaults to CTRL + D.
 	CloseKey rune
 
+	// InterruptKey is the key which abandons the current line, making ReadLine
+	// return ErrInterrupt.  Defaults to CTRL + C.  Set it to zero to have the
+	// key ignored instead.
+	InterruptKey rune
+
+	// InterruptReturnsLine, when true, makes ReadLine return whatever had been
+	// typed along with ErrInterrupt, rather than an empty string
+	InterruptReturnsLine bool
+
+	// OnPaste, if non-nil, is called with the full text of each bracketed
+	// paste, and can change or reject it before it goes into the line.  When
+	// OnPaste is set (or MaxPasteSize is nonzero), pasted text is inserted as
+	// a whole: a pasted newline becomes part of the line rather than
+	// submitting it, and ErrPasteIndicator is never returned.
+	OnPaste func(e *PasteEvent)
+
+	// MaxPasteSize, if nonzero, is the most bytes of a bracketed paste which
+	// will be kept; anything beyond that is thrown away, and the PasteEvent is
+	// marked as truncated
+	MaxPasteSize int
+
+	// OnResume, if non-nil, is called after the process is continued following
+	// a CTRL+Z suspend (see EnableSuspend), so applications can redraw
+	// anything besides the input
+	OnResume func()
+
+	// ChordTimeout is how long the Reader waits for the next key of a chord
+	// (see Bind) before handling the keys typed so far as usual.  The default
+	// of zero waits until the next key.
+	ChordTimeout time.Duration
+
 	// line is the current line being entered, and the cursor position
 	line *Line
 
@@ -63,28 +141,117 @@ type Reader struct {
 	// progress.
 	pasteActive bool
 
-	// history contains previously entered commands so that they can be
-	// accessed with the up and down keys.
-	history stRingBuffer
+	// pasteBuf collects the paste in progress when OnPaste or MaxPasteSize is
+	// set, and pasteTruncated is true if any of it had to be dropped
+	pasteBuf       []byte
+	pasteTruncated bool
+
+	// History contains previously entered commands so that they can be
+	// accessed with the up and down keys.  It defaults to a new History of
+	// DefaultHistorySize entries, but can be replaced in order to share a
+	// single history between readers, or set to nil to disable history.
+	History *History
+
 	// historyIndex stores the currently accessed history entry, where zero
 	// means the immediately previous entry.
 	historyIndex int
 	// When navigating up and down the history it's possible to return to
 	// the incomplete, initial line. That value is stored in
-	// historyPending.
-	historyPending string
+	// historyPending, and the cursor position in historyPendingPos.
+	historyPending    string
+	historyPendingPos int
+
+	// historyPrefix holds the text left of the cursor at the time history
+	// navigation began, for use when HistoryPrefixSearch is on
+	historyPrefix string
+
+	// completion holds the state of tab completion while the user is tabbing
+	// through candidates
+	completion *completion
+
+	// suggestion is the text the Suggester offered after the last keypress
+	suggestion []rune
+
+	// invalid holds the message from a failed validation, and invalidLine the
+	// text which failed, so the message can be cleared once the text changes
+	invalid     string
+	invalidLine string
+
+	// revealed is toggled by RevealKey
+	revealed bool
+
+	// suspendFD and suspendState are set up by EnableSuspend
+	suspendFD    int
+	suspendState *State
+
+	// modes are the TerminalModes a Prompt or AbsPrompt turned on for the
+	// current ReadLine, which have to be turned off while suspended
+	modes *TerminalModes
+
+	// redraw, if non-nil, is set by a Prompt or AbsPrompt to draw everything
+	// again after the screen may have been changed out from under it
+	redraw func()
+
+	// lineChanged, if non-nil, is set by a Prompt to draw the line after it's
+	// been changed by something other than a keypress
+	lineChanged func()
+
+	// restoreCursor, if non-nil, is set by a Prompt or AbsPrompt to put back
+	// the cursor's shape while the process is suspended
+	restoreCursor func()
+
+	// quotePending is true when CTRL+V was the last key, so the next key goes
+	// into the line as-is
+	quotePending bool
+
+	// chords holds the Reader's key bindings, and chordKeys the keys held back
+	// while a chord is being typed.  chordTimer handles them as usual after
+	// ChordTimeout, and chordGen tells it whether they've since been dealt
+	// with.
+	chords     []chordBinding
+	chordKeys  []Keypress
+	chordTimer *time.Timer
+	chordGen   int
+
+	// argument is the numeric argument typed so far, while argumentActive is
+	// true.  argumentDigits is true once a digit has been typed, and
+	// argumentNegative once Alt+- has been.
+	argument         int
+	argumentActive   bool
+	argumentDigits   bool
+	argumentNegative bool
+
+	// macro holds the keys of the last keyboard macro, which are added to while
+	// recording is true.  macroPlays is how many times the macro has been
+	// asked to play once the current key is done, and playing is true while it
+	// plays.
+	macro      []Keypress
+	recording  bool
+	macroPlays int
+	playing    bool
+
+	// lastArgActive is true when the last key inserted a previous entry's last
+	// argument, which started at lastArgStart and came from the lastArgN'th
+	// previous entry, so that another Alt+. can replace it
+	lastArgActive bool
+	lastArgStart  int
+	lastArgN      int
 }
 
 // NewReader runs a terminal reader on the given io.Reader. If the Reader is a
 // local terminal, that terminal must first have been put into raw mode.
 func NewReader(r io.Reader) *Reader {
-	return &Reader{
+	var reader = &Reader{
 		keyReader:     NewKeyReader(r),
 		MaxLineLength: DefaultMaxLineLength,
 		CloseKey:      KeyCtrlD,
+		InterruptKey:  KeyCtrlC,
+		History:       NewHistory(DefaultHistorySize),
 		historyIndex:  -1,
 		line:          &Line{},
 	}
+	reader.bindDefaultChords()
+	return reader
 }
 
 // handleKeypress processes the given keypress data and, optionally, returns a
@@ -93,6 +260,20 @@ func (r *Reader) handleKeypress(kp Keypress) (line string, ok bool) {
 	r.m.Lock()
 	defer r.m.Unlock()
 
+	r.line.IsSeparator = r.WordSeparator
+	r.recordKeypress(kp)
+	// Pasted and quoted keys go into the line as they are, so they're never
+	// part of a chord
+	if r.pasteActive || r.quotePending {
+		return r.handleKey(kp)
+	}
+	return r.dispatchChord(kp)
+}
+
+// handleKey processes a single keypress once it's known not to be part of a
+// chord
+func (r *Reader) handleKey(kp Keypress) (line string, ok bool) {
+	// lock has to be held here
 	var e = &KeyEvent{Keypress: kp, Line: r.line}
 	if r.OnKeypress != nil {
 		r.OnKeypress(e)
@@ -103,6 +284,9 @@ func (r *Reader) handleKeypress(kp Keypress) (line string, ok bool) {
 	}
 
 	line, ok = r.processKeypress(kp)
+	e.submitted = ok
+	r.clearValidation()
+	r.updateSuggestion()
 
 	if r.AfterKeypress != nil {
 		r.AfterKeypress(e)
@@ -120,12 +304,67 @@ func (r *Reader) processKeypress(kp Keypress) (output string, ok bool) {
 		return
 	}
 
+	var yankingLastArg = r.lastArgActive
+	r.lastArgActive = false
+
+	if r.quotePending {
+		r.quotePending = false
+		r.insertQuoted(kp)
+		return
+	}
+
+	// A numeric argument repeats the next key.  Chords never get here, so an
+	// argument typed before one is given to the chord's function instead.
+	if r.argumentKeypress(kp) {
+		return
+	}
+	if r.argumentActive {
+		var n, _ = r.takeArgument()
+		return r.repeatKeypress(kp, n)
+	}
+
+	if r.RevealKey != 0 && key == r.RevealKey && kp.Modifier == ModNone {
+		r.revealed = !r.revealed
+		return
+	}
+
+	if r.Completer != nil {
+		if kp.Modifier == ModNone && (key == KeyTab || key == KeyBackTab) {
+			r.complete(key == KeyBackTab)
+			return
+		}
+		if r.completion != nil && r.completion.columns > 0 && r.menuKeypress(kp) {
+			return
+		}
+		r.completion = nil
+	}
+
+	if r.suggestionKeypress(kp) {
+		return
+	}
+
 	if kp.Modifier == ModAlt {
 		switch key {
-		case KeyLeft:
+		case KeyLeft, 'b', 'B':
 			line.MoveToLeftWord()
-		case KeyRight:
+		case KeyRight, 'f', 'F':
 			line.MoveToRightWord()
+		case 'd', 'D':
+			line.DeleteNextWord()
+		case KeyBackspace, KeyCtrlH:
+			line.DeletePreviousWord()
+		case 't', 'T':
+			line.TransposeWords()
+		case 'u', 'U':
+			line.UpcaseWord()
+		case 'l', 'L':
+			line.DowncaseWord()
+		case 'c', 'C':
+			line.CapitalizeWord()
+		case '.':
+			r.yankLastArg(yankingLastArg)
+		case KeyEnter:
+			r.addKey('\n')
 		}
 	}
 
@@ -145,36 +384,119 @@ func (r *Reader) processKeypress(kp Keypress) (output string, ok bool) {
 	case KeyEnd, KeyCtrlE:
 		line.MoveEnd()
 	case KeyUp:
+		if line.MoveUp() {
+			return
+		}
 		fetched := r.fetchPreviousHistory()
 		if !fetched {
 			return "", false
 		}
 	case KeyDown:
-		r.fetchNextHistory()
+		if !line.MoveDown() {
+			r.fetchNextHistory()
+		}
 	case KeyEnter:
+		if r.IncompleteInput != nil && r.IncompleteInput(line.String()) {
+			r.addKey('\n')
+			return
+		}
+		if !r.validate() {
+			return
+		}
 		output = line.String()
 		ok = true
 		line.Clear()
+		line.Overwrite = false
+		r.revealed = false
+	case KeyInsert:
+		line.Overwrite = !line.Overwrite
 	case KeyCtrlW:
-		line.EraseNPreviousChars(line.CountToLeftWord())
+		line.DeletePreviousWord()
 	case KeyCtrlK:
 		line.DeleteLine()
 	case KeyCtrlD, KeyDelete:
 		line.DeleteRuneUnderCursor()
 	case KeyCtrlU:
 		line.DeleteToBeginningOfLine()
+	case KeyCtrlT:
+		line.TransposeChars()
+	case KeyCtrlSpace:
+		line.SetMark()
+	case KeyCtrlV:
+		r.quotePending = true
 	default:
 		if !isPrintable(key) {
 			return
 		}
-		if len(line.Text) == r.MaxLineLength {
-			return
-		}
-		line.AddKeyToLine(key)
+		r.addKey(key)
+	}
+	return
+}
+
+// repeatKeypress processes kp n times, or the opposite key -n times if n is
+// negative, stopping early if the line is submitted
+func (r *Reader) repeatKeypress(kp Keypress, n int) (output string, ok bool) {
+	// lock has to be held here
+	if n < 0 {
+		kp, n = reverseKey(kp), -n
+	}
+	for i := 0; i < n && !ok; i++ {
+		output, ok = r.processKeypress(kp)
 	}
 	return
 }
 
+// addKey puts key into the line unless the line is already at its maximum
+// length.  In overwrite mode, key replaces the character under the cursor,
+// which is allowed even at the maximum length.
+func (r *Reader) addKey(key rune) {
+	// lock has to be held here
+	var overwrite = r.line.Overwrite && key != '\n'
+	if overwrite && r.line.canOverwrite() {
+		r.line.OverwriteKey(key)
+		return
+	}
+	if len(r.line.Text) >= r.MaxLineLength {
+		return
+	}
+	r.line.AddKeyToLine(key)
+}
+
+// yankLastArg inserts the last argument of the previous history entry.  If
+// again is true, the previous key did the same, so the argument it inserted is
+// replaced with the one from the entry before.
+func (r *Reader) yankLastArg(again bool) {
+	// lock has to be held here
+	if r.NoHistory || r.History == nil {
+		return
+	}
+
+	var n, start = 0, r.line.Pos
+	if again {
+		n, start = r.lastArgN+1, r.lastArgStart
+	}
+	var entry, ok = r.History.NthPreviousEntry(n)
+	if !ok {
+		// Keep the current argument so another press can't lose our place
+		r.lastArgActive = again
+		return
+	}
+
+	var arg = []rune(LastArgument(entry))
+	if len(r.line.Text)-(r.line.Pos-start)+len(arg) > r.MaxLineLength {
+		return
+	}
+	r.line.Replace(start, r.line.Pos, arg)
+	r.lastArgActive, r.lastArgStart, r.lastArgN = true, start, n
+}
+
+// ReadLineWithDefault is ReadLine, but with text already filled in for the
+// user to edit, and the cursor at pos
+func (r *Reader) ReadLineWithDefault(text string, pos int) (string, error) {
+	r.SetLine(text, pos)
+	return r.ReadLine()
+}
+
 // ReadPassword temporarily reads a password without saving to history
 func (r *Reader) ReadPassword() (line string, err error) {
 	oldNoHistory := r.NoHistory
@@ -184,9 +506,22 @@ func (r *Reader) ReadPassword() (line string, err error) {
 	return
 }
 
-// ReadLine returns a line of input from the terminal.
+// ReadLine returns a line of input from the terminal.  The line is added to
+// the History, but an error writing it to the History's AppendTo writer isn't
+// returned here; see History.Err.
 func (r *Reader) ReadLine() (line string, err error) {
+	r.m.RLock()
 	lineIsPasted := r.pasteActive
+	r.m.RUnlock()
+
+	// Keys waiting on the next one mustn't carry over into the next line
+	defer func() {
+		if err != nil {
+			r.m.Lock()
+			r.clearPending()
+			r.m.Unlock()
+		}
+	}()
 
 	for {
 		lineOk := false
@@ -204,35 +539,56 @@ func (r *Reader) ReadLine() (line string, err error) {
 
 			r.m.RLock()
 			lineLen := len(r.line.Text)
+			argumentActive := r.argumentActive
+			pasteActive := r.pasteActive
 			r.m.RUnlock()
 
-			if !r.pasteActive {
-				if key == r.CloseKey {
+			if !pasteActive && !r.quoting() {
+				if key == r.CloseKey && !argumentActive {
 					if lineLen == 0 {
 						return "", io.EOF
 					}
 				}
+				if key == r.InterruptKey && r.InterruptKey != 0 && kp.Modifier == ModNone {
+					line = r.interrupt(kp)
+					if !r.InterruptReturnsLine {
+						line = ""
+					}
+					return line, ErrInterrupt
+				}
+				if r.suspending(kp) {
+					if err = r.suspend(); err != nil {
+						return
+					}
+					continue
+				}
 				if key == KeyPasteStart {
-					r.pasteActive = true
-					if lineLen == 0 {
+					r.startPaste()
+					if lineLen == 0 && !r.structuredPaste() {
 						lineIsPasted = true
 					}
 					continue
 				}
-			} else if key == KeyPasteEnd {
-				r.pasteActive = false
+			} else if pasteActive && key == KeyPasteEnd {
+				r.endPaste(kp)
+				continue
+			} else if pasteActive && r.structuredPaste() {
+				r.collectPaste(kp.Raw)
 				continue
 			}
-			if !r.pasteActive {
+			if !pasteActive {
 				lineIsPasted = false
 			}
 			line, lineOk = r.handleKeypress(kp)
+			if !lineOk {
+				line, lineOk = r.playMacro()
+			}
 		}
 
 		if lineOk {
-			if !r.NoHistory {
+			if !r.NoHistory && r.History != nil {
 				r.historyIndex = -1
-				r.history.Add(line)
+				r.History.Add(line)
 			}
 			if lineIsPasted {
 				err = ErrPasteIndicator
@@ -242,6 +598,126 @@ func (r *Reader) ReadLine() (line string, err error) {
 	}
 }
 
+// interrupt abandons the current line, returning its text.  AfterKeypress is
+// called before the line is cleared so that anything drawing the line can get
+// rid of autosuggestions and menus while still showing what had been typed.
+func (r *Reader) interrupt(kp Keypress) string {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.completion = nil
+	r.suggestion = nil
+	r.invalid = ""
+	if r.AfterKeypress != nil {
+		r.AfterKeypress(&KeyEvent{Keypress: kp, Line: r.line})
+	}
+
+	var text = r.line.String()
+	r.line.Clear()
+	r.historyIndex = -1
+	r.revealed = false
+	r.line.Overwrite = false
+	r.clearPending()
+	r.lastArgActive = false
+	return text
+}
+
+// clearPending forgets keys which were waiting on the next one: a CTRL+V, a
+// partly typed chord, and a numeric argument
+func (r *Reader) clearPending() {
+	// lock has to be held here
+	r.quotePending = false
+	r.clearChord()
+	r.clearArgument()
+}
+
+// SetLine replaces the input line with text and puts the cursor at pos.  It's
+// safe to call from any goroutine, as when a server pushes a correction, but
+// not from OnKeypress or AfterKeypress, which should change e.Line instead.
+func (r *Reader) SetLine(text string, pos int) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var runes = []rune(text)
+	if len(runes) > r.MaxLineLength {
+		runes = runes[:r.MaxLineLength]
+	}
+	if pos < 0 {
+		pos = 0
+	}
+	if pos > len(runes) {
+		pos = len(runes)
+	}
+	r.line.Set(runes, pos)
+	r.line.Pos = r.line.clusterStart(pos)
+	r.historyIndex = -1
+	r.edited()
+}
+
+// InsertText puts text into the input line at the cursor, as if the user had
+// typed it.  As with SetLine, it's safe to call from any goroutine, but not
+// from OnKeypress or AfterKeypress.
+func (r *Reader) InsertText(text string) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var runes = []rune(text)
+	if room := r.MaxLineLength - len(r.line.Text); len(runes) > room {
+		if room < 0 {
+			room = 0
+		}
+		runes = runes[:room]
+	}
+	r.line.InsertText(runes)
+	r.edited()
+}
+
+// ClearLine erases the input line.  As with SetLine, it's safe to call from
+// any goroutine, but not from OnKeypress or AfterKeypress.
+func (r *Reader) ClearLine() {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.line.Clear()
+	r.historyIndex = -1
+	r.edited()
+}
+
+// edited updates state which depends on the line after it's been changed
+// without a keypress, then lets a Prompt draw the changes
+func (r *Reader) edited() {
+	// lock has to be held here
+	r.completion = nil
+	r.clearValidation()
+	r.updateSuggestion()
+	if r.lineChanged != nil {
+		r.lineChanged()
+	}
+}
+
+// Overwrite returns true if the Reader is in overwrite mode, where typed
+// characters replace the character under the cursor.  The Insert key toggles
+// overwrite mode, and each submitted line puts the Reader back into insert
+// mode.
+func (r *Reader) Overwrite() bool {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	return r.line.Overwrite
+}
+
+// SetOverwrite turns overwrite mode on or off.  As with SetLine, it's safe to
+// call from any goroutine, but not from OnKeypress or AfterKeypress, which
+// should change e.Line.Overwrite instead.
+func (r *Reader) SetOverwrite(overwrite bool) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.line.Overwrite = overwrite
+	if r.lineChanged != nil {
+		r.lineChanged()
+	}
+}
+
 // LinePos returns the current input line and cursor position
 func (r *Reader) LinePos() (string, int) {
 	r.m.RLock()
@@ -256,50 +732,83 @@ func (r *Reader) Pos() int {
 	return r.line.Pos
 }
 
-// fetchPreviousHistory sets the input line to the previous entry in our history
+// fetchPreviousHistory sets the input line to the previous entry in our
+// history, or the previous one matching the search prefix if
+// HistoryPrefixSearch is on
 func (r *Reader) fetchPreviousHistory() bool {
 	// lock has to be held here
-	if r.NoHistory {
+	if r.NoHistory || r.History == nil {
 		return false
 	}
 
-	entry, ok := r.history.NthPreviousEntry(r.historyIndex + 1)
-	if !ok {
-		return false
-	}
+	var prefix = r.historyPrefix
 	if r.historyIndex == -1 {
-		r.historyPending = string(r.line.Text)
+		prefix = string(r.line.Text[:r.line.Pos])
+	}
+
+	var current = r.line.String()
+	for n := r.historyIndex + 1; ; n++ {
+		entry, ok := r.History.NthPreviousEntry(n)
+		if !ok {
+			return false
+		}
+		if r.HistoryPrefixSearch && (entry == current || !strings.HasPrefix(entry, prefix)) {
+			continue
+		}
+
+		if r.historyIndex == -1 {
+			r.historyPending = current
+			r.historyPendingPos = r.line.Pos
+			r.historyPrefix = prefix
+		}
+		r.historyIndex = n
+		runes := []rune(entry)
+		r.line.Set(runes, len(runes))
+		return true
 	}
-	r.historyIndex++
-	runes := []rune(entry)
-	r.line.Set(runes, len(runes))
-	return true
 }
 
-// fetchNextHistory sets the input line to the next entry in our history
+// fetchNextHistory sets the input line to the next entry in our history (or
+// the next entry matching the search prefix if HistoryPrefixSearch is on),
+// restoring the line the user had been typing once there are no more
 func (r *Reader) fetchNextHistory() {
 	// lock has to be held here
-	if r.NoHistory {
+	if r.NoHistory || r.History == nil || r.historyIndex == -1 {
 		return
 	}
 
-	switch r.historyIndex {
-	case -1:
-		return
-	case 0:
-		runes := []rune(r.historyPending)
-		r.line.Set(runes, len(runes))
-		r.historyIndex--
-	default:
-		entry, ok := r.history.NthPreviousEntry(r.historyIndex - 1)
-		if ok {
-			r.historyIndex--
-			runes := []rune(entry)
-			r.line.Set(runes, len(runes))
+	var current = r.line.String()
+	for n := r.historyIndex - 1; n >= 0; n-- {
+		entry, ok := r.History.NthPreviousEntry(n)
+		if !ok {
+			break
 		}
+		if r.HistoryPrefixSearch && (entry == current || !strings.HasPrefix(entry, r.historyPrefix)) {
+			continue
+		}
+
+		r.historyIndex = n
+		runes := []rune(entry)
+		r.line.Set(runes, len(runes))
+		return
 	}
+
+	runes := []rune(r.historyPending)
+	r.line.Set(runes, r.historyPendingPos)
+	r.historyIndex = -1
 }
 
+type interruptError struct{}
+
+func (interruptError) Error() string {
+	return "terminal: interrupted"
+}
+
+// ErrInterrupt is returned from ReadLine when the user presses the
+// InterruptKey.  The line is abandoned: it isn't added to history, and it's
+// only returned if InterruptReturnsLine is true.
+var ErrInterrupt = interruptError{}
+
 type pasteIndicatorError struct{}
 
 func (pasteIndicatorError) Error() string {
@@ -311,43 +820,3 @@ func (pasteIndicatorError) Error() string {
 // that the returned line consists only of pasted data. Programs may wish to
 // interpret pasted data more literally than typed data.
 var ErrPasteIndicator = pasteIndicatorError{}
-
-// stRingBuffer is a ring buffer of strings.
-type stRingBuffer struct {
-	// entries contains max elements.
-	entries []string
-	max     int
-	// head contains the index of the element most recently added to the ring.
-	head int
-	// size contains the number of elements in the ring.
-	size int
-}
-
-func (s *stRingBuffer) Add(a string) {
-	if s.entries == nil {
-		const defaultNumEntries = 100
-		s.entries = make([]string, defaultNumEntries)
-		s.max = defaultNumEntries
-	}
-
-	s.head = (s.head + 1) % s.max
-	s.entries[s.head] = a
-	if s.size < s.max {
-		s.size++
-	}
-}
-
-// NthPreviousEntry returns the value passed to the nth previous call to Add.
-// If n is zero then the immediately prior value is returned, if one, then the
-// next most recent, and so on. If such an element doesn't exist then ok is
-// false.
-func (s *stRingBuffer) NthPreviousEntry(n int) (value string, ok bool) {
-	if n >= s.size {
-		return "", false
-	}
-	index := s.head - n
-	if index < 0 {
-		index += s.max
-	}
-	return s.entries[index], true
-}
@@ -7,6 +7,7 @@ package terminal
 import (
 	"io"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -52,6 +53,23 @@ func TestClose(t *testing.T) {
 	}
 }
 
+func TestCloseClearsPendingKeys(t *testing.T) {
+	// A CTRL+V, a partly typed chord, or a numeric argument which is cut off
+	// by the end of input is forgotten rather than applied to the next line
+	for _, in := range []string{"a\x16", "a\x18", "a\x1b3"} {
+		var r = NewReader(&MockReader{toSend: []byte(in)})
+		if _, err := r.ReadLine(); err != io.EOF {
+			t.Errorf("Expected EOF for %q, got %v", in, err)
+		}
+		if r.quoting() || r.PendingChord() != "" {
+			t.Errorf("Expected no pending keys after %q", in)
+		}
+		if _, ok := r.NumericArgument(); ok {
+			t.Errorf("Expected no numeric argument after %q", in)
+		}
+	}
+}
+
 var keyPressTests = []struct {
 	in             string
 	line           string
@@ -202,6 +220,93 @@ var keyPressTests = []struct {
 		line:           "efgh",
 		throwAwayLines: 1,
 	},
+	{
+		// ^W stops at punctuation
+		in:   "cd path/to/file\x17\r",
+		line: "cd path/to/",
+	},
+	{
+		// Alt+B twice, Alt+D deletes the word under the cursor
+		in:   "a,b c\x1bb\x1bb\x1bd\r",
+		line: "a, c",
+	},
+	{
+		// Alt+B, Alt+F moves back to the start of the next word
+		in:   "foo bar\x1bb\x1bb\x1bfX\r",
+		line: "foo Xbar",
+	},
+	{
+		// Alt+Backspace deletes the previous word
+		in:   "foo bar\x1b\x7f\r",
+		line: "foo ",
+	},
+	{
+		// ^T transposes and Alt+U upcases
+		in:   "ab\x14 cd\x1bb\x1bu\r",
+		line: "ba CD",
+	},
+	{
+		// ^Space sets the mark, and ^X^X swaps it with the cursor
+		in:   "ab\x00cd\x18\x18X\r",
+		line: "abXcd",
+	},
+	{
+		// any other key after ^X isn't a chord, so both keys are handled as
+		// usual, and ^X does nothing on its own
+		in:   "ab\x18cd\r",
+		line: "abcd",
+	},
+	{
+		// a quoted ^X goes into the line rather than starting a chord
+		in:   "a\x16\x18\x18b\r",
+		line: "a\x18b",
+	},
+	{
+		// Alt+Enter inserts a newline rather than submitting
+		in:   "ab\x1b\rcd\r",
+		line: "ab\ncd",
+	},
+	{
+		// up moves to the same column in the previous row, clamped to its end
+		in:   "ab\x1b\rcdef\x1b[AX\r",
+		line: "abX\ncdef",
+	},
+	{
+		// down from the first row goes to the next row rather than history
+		in:   "abc\x1b\rde\x1b[A\x1b[D\x1b[BX\r",
+		line: "abc\ndXe",
+	},
+	{
+		// home and end work on the current row
+		in:   "ab\x1b\rcd\x1b[A\x01X\x05Y\r",
+		line: "XabY\ncd",
+	},
+	{
+		// Alt+digits repeat the next key
+		in:   "abcdef\x1b3\x1b[D\x7f\r",
+		line: "abdef",
+	},
+	{
+		// after Alt+digit, plain digits add to the argument
+		in:   "\x1b12x\r",
+		line: "xxxxxxxxxxxx",
+	},
+	{
+		// Alt+- reverses the next key
+		in:   "abcdef\x1b[D\x1b[D\x1b-\x1b[DX\x01\x1b-\x7f\r",
+		line: "bcdeXf",
+	},
+	{
+		// ^X ( and ^X ) record a macro, and ^X e plays it, repeated by an
+		// argument
+		in:   "\x18(ab\x18)\x18e\x1b3\x18e\r",
+		line: "ababababab",
+	},
+	{
+		// macros can't be played while recording
+		in:   "\x18(a\x18e\x18)\x18e\r",
+		line: "aa",
+	},
 	{
 		// Lines consisting entirely of pasted data should be indicated as such.
 		in:   "\x1b[200~a\r",
@@ -298,3 +403,132 @@ func TestMakeRawState(t *testing.T) {
 		t.Errorf("states do not match; was %v, expected %v", raw, st)
 	}
 }
+
+var historyPrefixTests = []struct {
+	in   string
+	line string
+}{
+	{
+		// up with "git " typed skips the non-matching "ls" entry
+		in:   "git \x1b[A\r",
+		line: "git status",
+	},
+	{
+		// up twice walks back through only the matching entries
+		in:   "git \x1b[A\x1b[A\r",
+		line: "git commit",
+	},
+	{
+		// running out of matches leaves the last match in place
+		in:   "git \x1b[A\x1b[A\x1b[A\r",
+		line: "git commit",
+	},
+	{
+		// down past the newest match restores the text and cursor position
+		in:   "gitx\x1b[D\x1b[A\x1b[BY\r",
+		line: "gitYx",
+	},
+	{
+		// only text left of the cursor counts as the prefix
+		in:   "lsx\x1b[D\x1b[A\r",
+		line: "ls",
+	},
+}
+
+func TestHistoryPrefixSearch(t *testing.T) {
+	for i, test := range historyPrefixTests {
+		c := &MockReader{toSend: []byte(test.in)}
+		ss := NewReader(c)
+		ss.HistoryPrefixSearch = true
+		for _, entry := range []string{"git commit", "ls", "git status", "ls"} {
+			ss.History.Add(entry)
+		}
+		line, _ := ss.ReadLine()
+		if line != test.line {
+			t.Errorf("Line resulting from test %d was '%s', expected '%s'", i, line, test.line)
+		}
+	}
+}
+
+var completionTests = []struct {
+	in   string
+	line string
+}{
+	{
+		// a single candidate is inserted right away
+		in:   "say fo\t\r",
+		line: "say foobar",
+	},
+	{
+		// multiple candidates have their common prefix inserted
+		in:   "say b\t\r",
+		line: "say ba",
+	},
+	{
+		// tabbing again cycles through candidates
+		in:   "say b\t\t\r",
+		line: "say bar",
+	},
+	{
+		in:   "say b\t\t\t\t\r",
+		line: "say bar",
+	},
+	{
+		// shift-tab cycles backward
+		in:   "say b\t\x1b[Z\r",
+		line: "say baz",
+	},
+	{
+		// text after the cursor is preserved
+		in:   "say b!\x1b[D\t\t\t\r",
+		line: "say baz!",
+	},
+	{
+		// any other key ends completion
+		in:   "say b\t\tx\t\r",
+		line: "say barx",
+	},
+	{
+		// no candidates, no change
+		in:   "say q\t\r",
+		line: "say q",
+	},
+}
+
+func TestCompletion(t *testing.T) {
+	var words = []string{"foobar", "bar", "baz"}
+	var completer = CompleterFunc(func(line string, pos int) ([]string, int, int) {
+		var runes = []rune(line)
+		var start = pos
+		for start > 0 && runes[start-1] != ' ' {
+			start--
+		}
+		var prefix = string(runes[start:pos])
+		var candidates []string
+		for _, w := range words {
+			if strings.HasPrefix(w, prefix) {
+				candidates = append(candidates, w)
+			}
+		}
+		return candidates, start, pos
+	})
+
+	for i, test := range completionTests {
+		c := &MockReader{toSend: []byte(test.in)}
+		ss := NewReader(c)
+		ss.Completer = completer
+		line, _ := ss.ReadLine()
+		if line != test.line {
+			t.Errorf("Line resulting from test %d was '%s', expected '%s'", i, line, test.line)
+		}
+	}
+
+	c := &MockReader{toSend: []byte("say b\t\t")}
+	ss := NewReader(c)
+	ss.Completer = completer
+	ss.ReadLine()
+	candidates, selected := ss.Completions()
+	if strings.Join(candidates, ",") != "bar,baz" || selected != 0 {
+		t.Errorf("Expected candidates [bar baz] with 0 selected, got %q and %d", candidates, selected)
+	}
+}
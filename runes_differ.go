@@ -20,3 +20,21 @@ func runesDiffer(a, b []rune) int {
 
 	return -1
 }
+
+// stylesDiffer is just like runesDiffer, but for slices of SGR style strings
+func stylesDiffer(a, b []string) int {
+	for i, s := range a {
+		if len(b) <= i {
+			return i
+		}
+		if s != b[i] {
+			return i
+		}
+	}
+
+	if len(b) > len(a) {
+		return len(a)
+	}
+
+	return -1
+}
@@ -27,3 +27,20 @@ func TestRunesDiffer(t *testing.T) {
 		t.Error("Expected difference at index 3 (mismatched length doesn't matter if they differ early)")
 	}
 }
+
+func TestStylesDiffer(t *testing.T) {
+	var a = []string{"", "", "2"}
+	var b = []string{"", "", "2"}
+	if stylesDiffer(a, b) != -1 {
+		t.Error("Expected no differences")
+	}
+
+	b[2] = ""
+	if stylesDiffer(a, b) != 2 {
+		t.Error("Expected difference at index 2")
+	}
+
+	if stylesDiffer(a, a[:1]) != 1 {
+		t.Error("Expected difference at index 1 (where the shorter slice ends)")
+	}
+}
@@ -7,7 +7,8 @@ const ScrollBy = 10
 // A Scroller is a Line filter for taking the internal Line's state and giving
 // an output widget what should be drawn to the screen
 type Scroller struct {
-	// InputWidth should be set to the terminal width or smaller.  If this is
+	// InputWidth should be set to the terminal width or smaller, and is
+	// measured in terminal cells, so wide characters take up two.  If this is
 	// equal to or larger than MaxWidth, no scrolling will occur
 	InputWidth int
 
@@ -57,21 +58,48 @@ func (s *Scroller) Reset() {
 	s.ScrollOffset = 0
 }
 
+// scrolling returns true if InputWidth and MaxLineLength are set up such that
+// scrolling can happen
+func (s *Scroller) scrolling() bool {
+	return s.InputWidth >= 1 && s.MaxLineLength >= 1
+}
+
+// offset returns the index of the first rune Filter put in its output
+func (s *Scroller) offset() int {
+	if !s.scrolling() {
+		return 0
+	}
+	return s.ScrollOffset
+}
+
+// cursorCells returns the number of cells from the scroll offset to the
+// cursor, which is negative if the cursor is left of the offset
+func (s *Scroller) cursorCells(l *Line) int {
+	var start, end = s.ScrollOffset, l.Pos
+	if start > len(l.Text) {
+		start = len(l.Text)
+	}
+	if start > end {
+		return -RunesWidth(l.Text[end:start]) - (s.ScrollOffset - start)
+	}
+	return RunesWidth(l.Text[start:end])
+}
+
 // Filter looks at the Input's line and our scroll properties to figure out
-// if we should scroll, and what should be drawn in the input area
+// if we should scroll, and what should be drawn in the input area.  The
+// returned int is the cursor's index in the returned runes; because of wide
+// characters, this isn't necessarily its screen column.  A wide character is
+// never split at either edge of the input area.
 func (s *Scroller) Filter(l *Line) ([]rune, int) {
-	if s.InputWidth < 1 || s.MaxLineLength < 1 {
+	if !s.scrolling() {
 		return l.Text, l.Pos
 	}
 
-	// Check for new cursor location being off-screen
-	var cursorLoc = l.Pos - s.ScrollOffset
 	var lineLen = len(l.Text)
 
 	// Too far left
-	for cursorLoc <= 0 && s.ScrollOffset > 0 {
+	for s.cursorCells(l) <= 0 && s.ScrollOffset > 0 {
 		s.ScrollOffset -= s.ScrollBy
-		cursorLoc += s.ScrollBy
 	}
 	if s.ScrollOffset < 0 {
 		s.ScrollOffset = 0
@@ -79,27 +107,41 @@ func (s *Scroller) Filter(l *Line) ([]rune, int) {
 
 	// Too far right
 	var maxScroll = s.MaxLineLength - s.InputWidth
-	for cursorLoc >= s.InputWidth-1 && s.ScrollOffset < maxScroll {
+	for s.cursorCells(l) >= s.InputWidth-1 && s.ScrollOffset < maxScroll {
 		s.ScrollOffset += s.ScrollBy
-		cursorLoc -= s.ScrollBy
 	}
 	if s.ScrollOffset >= maxScroll {
 		s.ScrollOffset = maxScroll
 	}
 
+	// Wide characters can leave the cursor past the input area even when we're
+	// scrolled as far as a line of narrow characters could need
+	for s.cursorCells(l) > s.InputWidth && s.ScrollOffset < l.Pos {
+		s.ScrollOffset += s.ScrollBy
+		if s.ScrollOffset > l.Pos {
+			s.ScrollOffset = l.Pos
+		}
+	}
+
 	// Figure out what we need to output next by pulling just the parts of the
-	// input runes that will be visible
-	var end = s.ScrollOffset + s.InputWidth
-	if end > lineLen {
-		end = lineLen
+	// input runes that will be visible, stopping before any wide character
+	// which would only partly fit
+	var offset = s.ScrollOffset
+	if offset > lineLen {
+		offset = lineLen
+	}
+	var end, cells = offset, 0
+	for end < lineLen && cells+RuneWidth(l.Text[end]) <= s.InputWidth {
+		cells += RuneWidth(l.Text[end])
+		end++
 	}
-	s.nextOutput = append(s.nextOutput[:0], l.Text[s.ScrollOffset:end]...)
-	if s.ScrollOffset > 0 && s.LeftOverflow != 0 {
+	s.nextOutput = append(s.nextOutput[:0], l.Text[offset:end]...)
+	if offset > 0 && s.LeftOverflow != 0 && len(s.nextOutput) > 0 {
 		s.nextOutput[0] = s.LeftOverflow
 	}
-	if s.InputWidth+s.ScrollOffset < lineLen && s.RightOverflow != 0 {
+	if end < lineLen && s.RightOverflow != 0 && len(s.nextOutput) > 0 {
 		s.nextOutput[len(s.nextOutput)-1] = s.RightOverflow
 	}
 
-	return s.nextOutput, cursorLoc
+	return s.nextOutput, l.Pos - offset
 }
@@ -0,0 +1,94 @@
+package terminal
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestReadLineWithDefault(t *testing.T) {
+	var out bytes.Buffer
+	var p = NewPrompt(&MockReader{toSend: []byte("\x7f\x7fX\r")}, &out, "Hostname: ")
+	var line, err = p.ReadLineWithDefault("myhost", 6)
+	if err != nil || line != "myhoX" {
+		t.Fatalf("Expected %q, got %q (err %v)", "myhoX", line, err)
+	}
+	if !strings.HasPrefix(out.String(), "Hostname: myhost") {
+		t.Errorf("Expected the default to be drawn after the prompt, got %q", out.String())
+	}
+
+	// The cursor is clamped, and the next line doesn't get the default
+	var r = NewReader(&MockReader{toSend: []byte("X\r\r")})
+	if line, _ = r.ReadLineWithDefault("ab", 99); line != "abX" {
+		t.Errorf("Expected %q, got %q", "abX", line)
+	}
+	if line, _ = r.ReadLine(); line != "" {
+		t.Errorf("Expected an empty line, got %q", line)
+	}
+}
+
+// syncBuffer is a bytes.Buffer which can be written and read from different
+// goroutines
+type syncBuffer struct {
+	m   sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return b.buf.String()
+}
+
+func TestSetLineConcurrent(t *testing.T) {
+	var in, w = io.Pipe()
+	var out syncBuffer
+	var p = NewPrompt(in, &out, "> ")
+
+	// Wait for each key to be processed so we know the order of changes
+	var keys = make(chan rune, 10)
+	p.AfterKeypress = func(e *KeyEvent) { keys <- e.Key }
+	var send = func(s string) {
+		w.Write([]byte(s))
+		<-keys
+	}
+
+	var done = make(chan string)
+	go func() {
+		var line, _ = p.ReadLine()
+		done <- line
+	}()
+
+	send("q")
+	p.SetLine("abc", 1)
+	p.InsertText("XY")
+	send("\x1b[C")
+	p.ClearLine()
+	p.InsertText("done")
+	send("!")
+	send("\r")
+
+	if line := <-done; line != "done!" {
+		t.Errorf("Expected %q, got %q", "done!", line)
+	}
+
+	// Each change is drawn as it happens
+	var expected = []string{"> q", "abc", "XYbc", "    ", "done", "!"}
+	var got = out.String()
+	var i int
+	for _, s := range expected {
+		var j = strings.Index(got[i:], s)
+		if j < 0 {
+			t.Fatalf("Expected %q after position %d in %q", s, i, got)
+		}
+		i += j + len(s)
+	}
+}
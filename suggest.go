@@ -0,0 +1,101 @@
+package terminal
+
+import "strings"
+
+// A Suggester offers an inline "autosuggestion" as the user types, like the
+// fish shell does.  Suggest is given the line so far, and returns the text
+// which would complete it, or an empty string if there's nothing to suggest.
+// The returned text does not include line itself.
+//
+// History is a Suggester which suggests the most recent matching entry.
+type Suggester interface {
+	Suggest(line string) string
+}
+
+// Suggest returns the rest of the most recent history entry which starts
+// with line, allowing a History to be used as a Reader's Suggester
+func (h *History) Suggest(line string) string {
+	if line == "" {
+		return ""
+	}
+
+	h.m.RLock()
+	defer h.m.RUnlock()
+	for n := 0; n < h.size; n++ {
+		var e, _ = h.nthPrevious(n)
+		if len(e.Text) > len(line) && strings.HasPrefix(e.Text, line) {
+			return e.Text[len(line):]
+		}
+	}
+	return ""
+}
+
+// updateSuggestion asks the Suggester for a new suggestion.  Suggestions are
+// only offered when the cursor is at the end of a non-empty line.
+func (r *Reader) updateSuggestion() {
+	// lock has to be held here
+	r.suggestion = r.suggestion[:0]
+	if r.Suggester == nil || r.pasteActive || len(r.line.Text) == 0 || r.line.Pos != len(r.line.Text) {
+		return
+	}
+	r.suggestion = append(r.suggestion, []rune(r.Suggester.Suggest(r.line.String()))...)
+}
+
+// acceptSuggestion adds n runes of the current suggestion to the line.  If n
+// is -1, the whole suggestion is added.
+func (r *Reader) acceptSuggestion(n int) {
+	// lock has to be held here
+	if n == -1 || n > len(r.suggestion) {
+		n = len(r.suggestion)
+	}
+	if room := r.MaxLineLength - len(r.line.Text); n > room {
+		n = room
+	}
+	for _, key := range r.suggestion[:n] {
+		r.line.AddKeyToLine(key)
+	}
+}
+
+// suggestionWordLength returns the number of runes in the suggestion up to the
+// end of its first word
+func (r *Reader) suggestionWordLength() int {
+	// lock has to be held here
+	var n int
+	for n < len(r.suggestion) && r.suggestion[n] == ' ' {
+		n++
+	}
+	for n < len(r.suggestion) && r.suggestion[n] != ' ' {
+		n++
+	}
+	return n
+}
+
+// suggestionKeypress handles the keys which accept a suggestion: right arrow
+// and End take the whole suggestion, and Alt+Right takes a word.  The return
+// is false if kp wasn't used.
+func (r *Reader) suggestionKeypress(kp Keypress) bool {
+	// lock has to be held here
+	if len(r.suggestion) == 0 || r.line.Pos != len(r.line.Text) {
+		return false
+	}
+
+	switch {
+	case kp.Modifier == ModNone && (kp.Key == KeyRight || kp.Key == KeyEnd || kp.Key == KeyCtrlE):
+		r.acceptSuggestion(-1)
+	case kp.Modifier == ModAlt && kp.Key == KeyRight:
+		r.acceptSuggestion(r.suggestionWordLength())
+	default:
+		return false
+	}
+	return true
+}
+
+// Suggestion returns the current autosuggestion: text which isn't part of the
+// line, but which the user can accept by pressing right arrow or End.  Output
+// layers should draw it after the line in a way that makes it clear it hasn't
+// been typed, such as in a dim color.
+func (r *Reader) Suggestion() string {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	return string(r.suggestion)
+}
@@ -0,0 +1,73 @@
+package terminal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var suggestionTests = []struct {
+	in   string
+	line string
+}{
+	{
+		// right arrow accepts the whole suggestion
+		in:   "git s\x1b[C\r",
+		line: "git status",
+	},
+	{
+		// so does End
+		in:   "git s\x1b[F\r",
+		line: "git status",
+	},
+	{
+		// alt+right accepts one word at a time
+		in:   "gi\x1b[1;3C\r",
+		line: "git",
+	},
+	{
+		in:   "gi\x1b[1;3C\x1b[1;3C\r",
+		line: "git commit",
+	},
+	{
+		// suggestions aren't offered unless the cursor is at the end of the line
+		in:   "gi\x1b[D\x1b[C\r",
+		line: "gi",
+	},
+	{
+		// suggestions never become part of the line on their own
+		in:   "git s\r",
+		line: "git s",
+	},
+}
+
+func TestSuggestions(t *testing.T) {
+	for i, test := range suggestionTests {
+		c := &MockReader{toSend: []byte(test.in)}
+		ss := NewReader(c)
+		ss.History.Add("git status")
+		ss.History.Add("git commit")
+		ss.Suggester = ss.History
+		line, _ := ss.ReadLine()
+		if line != test.line {
+			t.Errorf("Line resulting from test %d was '%s', expected '%s'", i, line, test.line)
+		}
+	}
+}
+
+func TestPromptSuggestion(t *testing.T) {
+	var out bytes.Buffer
+	var p = NewPrompt(&MockReader{toSend: []byte("git sx\r")}, &out, "> ")
+	p.History.Add("git status")
+	p.Suggester = p.History
+	p.ReadLine()
+
+	var s = out.String()
+	var ghost = strings.Index(s, "\x1b[2mtatus\x1b[0m")
+	if ghost == -1 {
+		t.Fatalf("Expected dim suggestion in output, got %q", s)
+	}
+	if !strings.Contains(s[ghost:], "x    ") {
+		t.Errorf("Expected suggestion to be erased by padding, got %q", s)
+	}
+}
@@ -0,0 +1,27 @@
+package terminal
+
+import "errors"
+
+// ErrSuspendUnsupported is returned by EnableSuspend on systems without job
+// control, such as Windows
+var ErrSuspendUnsupported = errors.New("terminal: suspend is not supported on this system")
+
+// suspending returns true if kp should suspend the process
+func (r *Reader) suspending(kp Keypress) bool {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	return r.suspendState != nil && kp.Key == KeyCtrlZ && kp.Modifier == ModNone
+}
+
+// resumed redraws the input after the process is continued
+func (r *Reader) resumed() {
+	r.m.Lock()
+	if r.redraw != nil {
+		r.redraw()
+	}
+	r.m.Unlock()
+
+	if r.OnResume != nil {
+		r.OnResume()
+	}
+}
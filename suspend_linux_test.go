@@ -0,0 +1,183 @@
+package terminal
+
+import (
+	"bytes"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+// openPTY returns the master and slave ends of a new pseudo-terminal
+func openPTY(t *testing.T) (master, slave *os.File) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		t.Skipf("unable to open /dev/ptmx: %s", err)
+	}
+
+	var unlock int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, master.Fd(), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); errno != 0 {
+		master.Close()
+		t.Skipf("unable to unlock pty: %s", errno)
+	}
+	var n uint32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, master.Fd(), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); errno != 0 {
+		master.Close()
+		t.Skipf("unable to get pty number: %s", errno)
+	}
+
+	slave, err = os.OpenFile("/dev/pts/"+strconv.Itoa(int(n)), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		t.Skipf("unable to open pty slave: %s", err)
+	}
+	return master, slave
+}
+
+func TestSuspend(t *testing.T) {
+	var master, slave = openPTY(t)
+	defer master.Close()
+	defer slave.Close()
+
+	var fd = int(slave.Fd())
+	var cooked, err = MakeRaw(fd)
+	if err != nil {
+		t.Fatalf("Unable to make pty raw: %s", err)
+	}
+	var raw, _ = GetState(fd)
+
+	// Rather than actually stopping, we check the terminal was restored and
+	// then continue ourselves
+	var stopped bool
+	defer func(orig func() error) { stopProcess = orig }(stopProcess)
+	stopProcess = func() error {
+		stopped = true
+		var st, _ = GetState(fd)
+		if *st != *cooked {
+			t.Errorf("Expected the cooked state to be restored while stopped")
+		}
+		return syscall.Kill(syscall.Getpid(), syscall.SIGCONT)
+	}
+
+	var out bytes.Buffer
+	var p = NewPrompt(&MockReader{toSend: []byte("ab\x1ac\r")}, &out, "> ")
+	var resumed bool
+	p.OnResume = func() { resumed = true }
+	if err = p.EnableSuspend(fd, cooked); err != nil {
+		t.Fatalf("Unable to enable suspend: %s", err)
+	}
+
+	var line string
+	line, err = p.ReadLine()
+	if err != nil || line != "abc" {
+		t.Fatalf("Expected %q, got %q (err %v)", "abc", line, err)
+	}
+	if !stopped || !resumed {
+		t.Fatalf("Expected to stop and resume, got %v and %v", stopped, resumed)
+	}
+
+	var st, _ = GetState(fd)
+	if *st != *raw {
+		t.Errorf("Expected raw mode after resuming")
+	}
+
+	// The prompt and line are drawn again from scratch
+	if !strings.Contains(out.String(), "ab\r\x1b[J> ab") {
+		t.Errorf("Expected a full redraw after resuming, got %q", out.String())
+	}
+}
+
+func TestSuspendCursor(t *testing.T) {
+	var master, slave = openPTY(t)
+	defer master.Close()
+	defer slave.Close()
+
+	var fd = int(slave.Fd())
+	var cooked, _ = MakeRaw(fd)
+	var out bytes.Buffer
+	var stopped string
+	defer func(orig func() error) { stopProcess = orig }(stopProcess)
+	stopProcess = func() error {
+		stopped = out.String()
+		return syscall.Kill(syscall.Getpid(), syscall.SIGCONT)
+	}
+
+	var p = NewPrompt(&MockReader{toSend: []byte("a\x1ab\r")}, &out, "> ")
+	p.Cursor = NewCursorStyle()
+	p.EnableSuspend(fd, cooked)
+	p.ReadLine()
+
+	// The shell gets the default cursor while we're stopped, and ours comes
+	// back once we're continued
+	if !strings.HasSuffix(stopped, "\x1b[0 q") {
+		t.Errorf("Expected the cursor to be restored before stopping, got %q", stopped)
+	}
+	if !strings.Contains(out.String()[len(stopped):], "\x1b[6 q") {
+		t.Errorf("Expected the cursor shape to be set again after resuming, got %q", out.String())
+	}
+}
+
+func TestSuspendIgnored(t *testing.T) {
+	var master, slave = openPTY(t)
+	defer master.Close()
+	defer slave.Close()
+
+	var fd = int(slave.Fd())
+	var cooked, _ = MakeRaw(fd)
+	signal.Ignore(syscall.SIGTSTP)
+
+	// signal.Reset doesn't make the signal stop counting as ignored, but
+	// asking for it does
+	defer func() {
+		var c = make(chan os.Signal, 1)
+		signal.Notify(c, syscall.SIGTSTP)
+		signal.Stop(c)
+	}()
+	defer func(orig func() error) { stopProcess = orig }(stopProcess)
+	stopProcess = func() error {
+		t.Errorf("Expected no attempt to stop while SIGTSTP is ignored")
+		return syscall.Kill(syscall.Getpid(), syscall.SIGCONT)
+	}
+
+	var r = NewReader(&MockReader{toSend: []byte("ab\x1ac\r")})
+	r.EnableSuspend(fd, cooked)
+	if line, err := r.ReadLine(); err != nil || line != "abc" {
+		t.Errorf("Expected %q, got %q (err %v)", "abc", line, err)
+	}
+}
+
+func TestSuspendAbsPrompt(t *testing.T) {
+	var master, slave = openPTY(t)
+	defer master.Close()
+	defer slave.Close()
+
+	var fd = int(slave.Fd())
+	var cooked, _ = MakeRaw(fd)
+	defer func(orig func() error) { stopProcess = orig }(stopProcess)
+	stopProcess = func() error {
+		return syscall.Kill(syscall.Getpid(), syscall.SIGCONT)
+	}
+
+	var out bytes.Buffer
+	var p = NewAbsPrompt(&MockReader{toSend: []byte("\x1a")}, &out, "> ")
+	p.EnableSuspend(fd, cooked)
+
+	// Draw once before the suspend is processed, so there's nothing to do
+	// unless the redraw flag is set
+	p.Reader.handleKeypress(Keypress{Key: 'a'})
+	p.Reader.handleKeypress(Keypress{Key: 'b'})
+	p.WriteChanges()
+	out.Reset()
+	p.ReadLine()
+
+	if !p.NeedWrite() {
+		t.Fatalf("Expected a write to be needed after resuming")
+	}
+	p.WriteChanges()
+	if !strings.Contains(out.String(), "\x1b[1;1H> ") {
+		t.Errorf("Expected the prompt to be redrawn, got %q", out.String())
+	}
+}
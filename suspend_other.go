@@ -0,0 +1,14 @@
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package terminal
+
+// EnableSuspend always returns ErrSuspendUnsupported on systems without job
+// control
+func (r *Reader) EnableSuspend(fd int, st *State) error {
+	return ErrSuspendUnsupported
+}
+
+// suspend is never called, as EnableSuspend can't succeed
+func (r *Reader) suspend() error {
+	return ErrSuspendUnsupported
+}
@@ -0,0 +1,77 @@
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package terminal
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// stopProcess sends SIGTSTP to this process.  It's a variable so tests can
+// avoid actually stopping.
+var stopProcess = func() error {
+	return syscall.Kill(syscall.Getpid(), syscall.SIGTSTP)
+}
+
+// EnableSuspend lets the user suspend the process with CTRL+Z, as they could
+// in a cooked-mode terminal.  fd must be the local terminal the Reader reads
+// from, already in raw mode, and st its state from before MakeRaw.  When
+// CTRL+Z is pressed, the terminal is restored to st and the process is
+// stopped; once it's continued (e.g., with "fg"), the terminal goes back into
+// raw mode, a Prompt or AbsPrompt redraws its input, and OnResume is called.
+func (r *Reader) EnableSuspend(fd int, st *State) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.suspendFD = fd
+	r.suspendState = st
+	return nil
+}
+
+// suspend stops the process until it's continued, restoring the terminal
+// while stopped.  It does nothing if SIGTSTP is being ignored.
+func (r *Reader) suspend() error {
+	// When SIGTSTP is ignored, as it can be under nohup or a supervisor, it
+	// won't stop us, and we'd wait forever for a SIGCONT
+	if signal.Ignored(syscall.SIGTSTP) {
+		return nil
+	}
+
+	r.m.RLock()
+	var fd, st, modes = r.suspendFD, r.suspendState, r.modes
+	r.m.RUnlock()
+
+	// The shell shouldn't get pastes or mouse reports while we're stopped, or
+	// be left with our cursor shape.  Once we're continued, the redraw sets
+	// the shape again.
+	var paused = modes.Enabled()
+	modes.Disable()
+	r.m.Lock()
+	if r.restoreCursor != nil {
+		r.restoreCursor()
+	}
+	r.m.Unlock()
+
+	var cont = make(chan os.Signal, 1)
+	signal.Notify(cont, syscall.SIGCONT)
+	defer signal.Stop(cont)
+
+	var err = Restore(fd, st)
+	if err == nil {
+		err = stopProcess()
+	}
+	if err == nil {
+		<-cont
+	}
+
+	if _, rawErr := MakeRaw(fd); err == nil {
+		err = rawErr
+	}
+	if paused {
+		if modesErr := modes.reenable(); err == nil {
+			err = modesErr
+		}
+	}
+	r.resumed()
+	return err
+}
@@ -17,9 +17,9 @@ type State struct {
 	termios syscall.Termios
 }
 
-// VisualLength returns the number of visible glyphs in a string.  This can be
-// useful for getting the length of a string which has ANSI color sequences,
-// but it doesn't count "wide" glyphs differently than others, and it won't
+// VisualLength returns the number of terminal cells a string takes up.  This
+// can be useful for getting the length of a string which has ANSI color
+// sequences.  Wide glyphs count as two cells (see RuneWidth), but it won't
 // handle ANSI cursor commands; e.g., it ignores "\x1b[D" rather than knowing
 // that the cursor position moved to the left.
 func VisualLength(s string) int {
@@ -36,7 +36,7 @@ func VisualLength(s string) int {
 		case r == '\x1b':
 			inEscapeSeq = true
 		default:
-			length++
+			length += RuneWidth(r)
 		}
 	}
 
@@ -88,8 +88,10 @@ func GetState(fd int) (*State, error) {
 // Restore restores the terminal connected to the given file descriptor to a
 // previous state.
 func Restore(fd int, state *State) error {
-	_, _, err := syscall.Syscall6(syscall.SYS_IOCTL, uintptr(fd), ioctlWriteTermios, uintptr(unsafe.Pointer(&state.termios)), 0, 0, 0)
-	return err
+	if _, _, err := syscall.Syscall6(syscall.SYS_IOCTL, uintptr(fd), ioctlWriteTermios, uintptr(unsafe.Pointer(&state.termios)), 0, 0, 0); err != 0 {
+		return err
+	}
+	return nil
 }
 
 // GetSize returns the dimensions of the given terminal.
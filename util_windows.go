@@ -61,9 +61,9 @@ type State struct {
 	mode uint32
 }
 
-// VisualLength returns the number of visible glyphs in a string.  This can be
-// useful for getting the length of a string which has ANSI color sequences,
-// but it doesn't count "wide" glyphs differently than others, and it won't
+// VisualLength returns the number of terminal cells a string takes up.  This
+// can be useful for getting the length of a string which has ANSI color
+// sequences.  Wide glyphs count as two cells (see RuneWidth), but it won't
 // handle ANSI cursor commands; e.g., it ignores "\x1b[D" rather than knowing
 // that the cursor position moved to the left.
 func VisualLength(s string) int {
@@ -80,7 +80,7 @@ func VisualLength(s string) int {
 		case r == '\x1b':
 			inEscapeSeq = true
 		default:
-			length++
+			length += RuneWidth(r)
 		}
 	}
 
@@ -0,0 +1,71 @@
+package terminal
+
+// A Validator checks a line when the user presses enter.  If it returns an
+// error, the line isn't submitted: the user stays in the editor, and the
+// error's text is available from Reader.ValidationMessage until the line is
+// next changed.  Return a *ValidationError to also move the cursor to the
+// problem.  The Reader's lock is held while the Validator runs, so it must
+// not call any of the Reader's methods.
+type Validator interface {
+	Validate(line string) error
+}
+
+// ValidatorFunc is an adapter to allow the use of an ordinary function as a
+// Validator
+type ValidatorFunc func(line string) error
+
+// Validate calls f(line)
+func (f ValidatorFunc) Validate(line string) error {
+	return f(line)
+}
+
+// ValidationError is an error a Validator can return in order to put the
+// cursor at a specific position (a rune index) in the line
+type ValidationError struct {
+	Message string
+	Pos     int
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// validate runs the Validator against the current line, storing any error and
+// moving the cursor to it.  It returns true if the line can be submitted.
+func (r *Reader) validate() bool {
+	// lock has to be held here
+	if r.Validator == nil {
+		return true
+	}
+
+	var err = r.Validator.Validate(r.line.String())
+	if err == nil {
+		return true
+	}
+
+	r.invalid = err.Error()
+	r.invalidLine = r.line.String()
+	if ve, ok := err.(*ValidationError); ok && ve.Pos >= 0 && ve.Pos <= len(r.line.Text) {
+		r.line.Pos = r.line.clusterStart(ve.Pos)
+	}
+	return false
+}
+
+// clearValidation forgets the validation error once the line has been edited
+func (r *Reader) clearValidation() {
+	// lock has to be held here
+	if r.invalid != "" && r.line.String() != r.invalidLine {
+		r.invalid = ""
+		r.invalidLine = ""
+	}
+}
+
+// ValidationMessage returns the text of the error from the last failed
+// validation, or an empty string if the line hasn't failed validation or has
+// been changed since
+func (r *Reader) ValidationMessage() string {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	return r.invalid
+}
@@ -0,0 +1,136 @@
+package terminal
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func validatePort(line string) error {
+	var n, err = strconv.Atoi(line)
+	if err != nil {
+		for i, r := range line {
+			if r < '0' || r > '9' {
+				return &ValidationError{Message: "not a number", Pos: len([]rune(line[:i]))}
+			}
+		}
+	}
+	if n < 1 || n > 65535 {
+		return errors.New("port out of range")
+	}
+	return nil
+}
+
+func TestValidator(t *testing.T) {
+	// The first enter fails, putting the cursor on the "x"; deleting it lets
+	// the second enter succeed
+	var c = &MockReader{toSend: []byte("80x80\r\x04\r"), bytesPerRead: 1}
+	var r = NewReader(c)
+	r.Validator = ValidatorFunc(validatePort)
+
+	var msgs []string
+	r.AfterKeypress = func(e *KeyEvent) {
+		msgs = append(msgs, r.invalid)
+		if e.Key == KeyEnter && !e.submitted && e.Line.Pos != 2 {
+			t.Errorf("Expected cursor at 2 after failed validation, got %d", e.Line.Pos)
+		}
+	}
+
+	var line, err = r.ReadLine()
+	if err != nil || line != "8080" {
+		t.Fatalf("Expected %q, got %q (err %v)", "8080", line, err)
+	}
+
+	// The message shows up after the first enter and is cleared by the edit
+	var expected = []string{"", "", "", "", "", "not a number", "", ""}
+	if strings.Join(msgs, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected messages %q, got %q", expected, msgs)
+	}
+}
+
+func TestValidationErrorInCluster(t *testing.T) {
+	// The error points at the combining mark, but the cursor has to land on
+	// the start of its cluster
+	var c = &MockReader{toSend: []byte("ae\u0301b\r")}
+	var r = NewReader(c)
+	r.Validator = ValidatorFunc(func(line string) error {
+		return &ValidationError{Message: "bad accent", Pos: 2}
+	})
+
+	var pos = -1
+	r.AfterKeypress = func(e *KeyEvent) {
+		if e.Key == KeyEnter {
+			pos = e.Line.Pos
+		}
+	}
+	r.ReadLine()
+	if pos != 1 {
+		t.Errorf("Expected cursor at 1 after failed validation, got %d", pos)
+	}
+}
+
+func TestPromptValidationError(t *testing.T) {
+	var out bytes.Buffer
+	var c = &MockReader{toSend: []byte("0\r\x7f1\r")}
+	var p = NewPrompt(c, &out, "> ")
+	p.Validator = ValidatorFunc(validatePort)
+
+	var line, _ = p.ReadLine()
+	if line != "1" {
+		t.Fatalf("Expected %q, got %q", "1", line)
+	}
+
+	var got = out.String()
+	var drawn = "\r\n\x1b[J\x1b[31mport out of range\x1b[0m\x1b[1A\r\x1b[3C"
+	var i = strings.Index(got, drawn)
+	if i < 0 {
+		t.Fatalf("Expected error drawn below the input, got %q", got)
+	}
+	if !strings.Contains(got[i+len(drawn):], "\r\n\x1b[J\x1b[1A") {
+		t.Errorf("Expected error to be erased after the edit, got %q", got)
+	}
+}
+
+func TestPromptMultiRowValidationError(t *testing.T) {
+	var out bytes.Buffer
+	var c = &MockReader{toSend: []byte("ab\x1b\rcd\r\x1b[D")}
+	var p = NewPrompt(c, &out, "> ")
+	p.Validator = ValidatorFunc(func(line string) error { return errors.New("no good") })
+
+	// Moving the cursor redraws both rows, which mustn't lose the error
+	var drawn string
+	p.AfterKeypress = func(e *KeyEvent) {
+		if e.Key == KeyLeft {
+			drawn = out.String()
+		}
+	}
+	p.ReadLine()
+
+	var i = strings.LastIndex(drawn, "cd")
+	if i < 0 || !strings.Contains(drawn[i:], "no good") {
+		t.Errorf("Expected the error to be drawn again after the cursor moved, got %q", drawn)
+	}
+}
+
+func TestAbsPromptValidationError(t *testing.T) {
+	var out bytes.Buffer
+	var c = &MockReader{toSend: []byte("0\r")}
+	var p = NewAbsPrompt(c, &out, "> ")
+	p.Validator = ValidatorFunc(validatePort)
+	p.ReadLine()
+
+	p.WriteChanges()
+	if !strings.Contains(out.String(), "\x1b[2;1H\x1b[31mport out of range\x1b[0m") {
+		t.Errorf("Expected error below the input, got %q", out.String())
+	}
+
+	out.Reset()
+	p.SetErrorLocation(10, 0)
+	p.WriteChanges()
+	var expected = "\x1b[2;1H" + strings.Repeat(" ", 17) + "\x1b[1;11H\x1b[31mport out of range\x1b[0m"
+	if !strings.Contains(out.String(), expected) {
+		t.Errorf("Expected error to move beside the input, got %q", out.String())
+	}
+}
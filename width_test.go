@@ -0,0 +1,86 @@
+package terminal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRuneWidth(t *testing.T) {
+	var tests = map[rune]int{
+		'a':      1,
+		'中':      2,
+		'ｱ':      1,
+		'Ａ':      2,
+		'\u3000': 2,
+		'\u0301': 0,
+		'\u200d': 0,
+		'\x01':   0,
+		'😀':      2,
+		'é':      1,
+		'각':      2,
+	}
+	for r, expected := range tests {
+		if got := RuneWidth(r); got != expected {
+			t.Errorf("RuneWidth(%q): expected %d, got %d", r, expected, got)
+		}
+	}
+
+	if got := VisualLength("\x1b[1m中文\x1b[0m ok"); got != 7 {
+		t.Errorf("Expected VisualLength to count wide runes twice, got %d", got)
+	}
+}
+
+func TestScrollerWide(t *testing.T) {
+	var s = NewScroller()
+	s.InputWidth = 5
+	s.MaxLineLength = 20
+	s.ScrollBy = 2
+
+	// The third wide rune would straddle the right edge, so it's left off
+	var l = &Line{Text: []rune("中文字"), Pos: 0}
+	var out, pos = s.Filter(l)
+	if string(out) != "中…" || pos != 0 {
+		t.Errorf("Expected %q at 0, got %q at %d", "中…", string(out), pos)
+	}
+
+	// With the cursor at the end, we scroll so it stays in the input area
+	l.Set([]rune("中文字中文"), 5)
+	out, pos = s.Filter(l)
+	if RunesWidth(out[:pos]) > s.InputWidth {
+		t.Errorf("Cursor is off the screen: %q with cursor at %d", string(out), pos)
+	}
+}
+
+func TestPromptWideCursor(t *testing.T) {
+	var out bytes.Buffer
+	// Type two wide runes, then move left once and insert "x"
+	var p = NewPrompt(&MockReader{toSend: []byte("中文\x1b[Dx\r")}, &out, "> ")
+	var line, _ = p.ReadLine()
+	if line != "中x文" {
+		t.Fatalf("Expected %q, got %q", "中x文", line)
+	}
+
+	// Left moves back two cells, and after redrawing "x文", the cursor has to
+	// move back over the two cells of "文"
+	var expected = "中文\x1b[2Dx文\x1b[2D"
+	if !strings.HasPrefix(out.String()[2:], expected) {
+		t.Errorf("Expected output to start with %q, got %q", expected, out.String()[2:])
+	}
+}
+
+func TestAbsPromptWideCursor(t *testing.T) {
+	var out bytes.Buffer
+	var p = NewAbsPrompt(&MockReader{toSend: []byte("中文")}, &out, "> ")
+	p.ReadLine()
+	p.WriteChanges()
+	if !strings.HasSuffix(out.String(), "\x1b[1;7H") {
+		t.Errorf("Expected cursor after four cells of input, got %q", out.String())
+	}
+}
+
+func TestFitTextWide(t *testing.T) {
+	if got := fitText("中文字", 4); got != "中…" {
+		t.Errorf("Expected %q, got %q", "中…", got)
+	}
+}